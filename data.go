@@ -1,19 +1,54 @@
 package godocrag
 
-import "strings"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"go/token"
+	"strings"
+)
 
 // Data is the representation of data parsed from Go packages
 type Data struct {
-	Type     string // package, function, struct, interface
-	Symbol   string // type name like Person or method like Person.Greet
-	Data     string
-	Package  string
-	Filename string
+	Type      string // package, function, struct, interface
+	Symbol    string // type name like Person or method like Person.Greet
+	Signature string // full declaration for functions and methods
+	Data      string
+	Package   string
+	Filename  string
+	Line      int // line in Filename where a function is declared
+
+	Module        string // path of the module containing Package
+	ModuleVersion string // version of the module, which is empty for the main module
+
+	// Undocumented is true for exported functions that have no doc comment
+	Undocumented bool
+
+	// Score is how relevant the Data is to a search query, where higher is better. It is only
+	// set for search results
+	Score float64
 
 	// children is just used during parsing in order to construct nested symbol names
 	children []Data
 }
 
+// Exported returns false if the symbol is not part of the package's exported API, like a method
+// on an unexported type. Package docs and package examples are always exported
+func (d Data) Exported() bool {
+	if d.Type == "package" {
+		return true
+	}
+	if d.Type == "example" && (d.Symbol == d.Package || strings.HasPrefix(d.Symbol, d.Package+"_")) {
+		return true
+	}
+
+	for _, part := range strings.Split(d.Symbol, ".") {
+		if !token.IsExported(strings.TrimPrefix(part, "*")) {
+			return false
+		}
+	}
+	return true
+}
+
 func (d *Data) AddChild(child Data) {
 	d.children = append(d.children, child)
 }
@@ -26,6 +61,12 @@ func (d Data) StringIndent(indent string) string {
 	sb.WriteString(d.Symbol)
 	sb.WriteRune(':')
 	sb.WriteRune(' ')
+	if d.Signature != "" {
+		sb.WriteString(strings.ReplaceAll(d.Signature, "\n", " "))
+		if d.Data != "" {
+			sb.WriteString(" // ")
+		}
+	}
 	sb.WriteString(strings.TrimSpace(strings.ReplaceAll(d.Data, "\n", " ")))
 
 	for _, child := range d.children {
@@ -39,3 +80,9 @@ func (d Data) StringIndent(indent string) string {
 func (d Data) String() string {
 	return d.StringIndent("")
 }
+
+// Hash returns a hash of the content that is embedded so changes can be detected
+func (d Data) Hash() string {
+	sum := sha256.Sum256([]byte(d.String()))
+	return hex.EncodeToString(sum[:])
+}
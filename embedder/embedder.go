@@ -2,91 +2,259 @@ package embedder
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
-	"strings"
+	"log"
+	"sync"
 
-	"github.com/ollama/ollama/api"
+	"golang.org/x/sync/errgroup"
 
 	godocrag "godoc-rag"
+	"godoc-rag/embedding"
+	"godoc-rag/store"
 )
 
 type Parser interface {
-	Parse() <-chan godocrag.Data
+	// Parse returns a channel of Data that is closed when parsing is done or ctx is cancelled
+	Parse(ctx context.Context) <-chan godocrag.Data
+	// Error returns any error from parsing after the channel is closed
 	Error() error
 }
 
 type Embedder struct {
-	p            Parser
-	db           *sql.DB
-	ollamaClient *api.Client
-	model        string
+	p         Parser
+	store     store.VectorStore
+	embedder  embedding.Embedder
+	workers   int
+	batchSize int
 }
 
-func New(db *sql.DB, ollamaClient *api.Client, p Parser, model string) Embedder {
+// New creates an Embedder that uses the specified number of workers to generate embeddings. Each
+// worker requests embeddings for up to batchSize chunks at a time
+func New(vs store.VectorStore, embedder embedding.Embedder, p Parser, workers, batchSize int) Embedder {
 	return Embedder{
-		db:           db,
-		ollamaClient: ollamaClient,
-		p:            p,
-		model:        model,
+		store:     vs,
+		embedder:  embedder,
+		p:         p,
+		workers:   max(workers, 1),
+		batchSize: max(batchSize, 1),
 	}
 }
 
-func (e Embedder) Embed(ctx context.Context) error {
-	for data := range e.p.Parse() {
-		// Store chunks and get their IDs
-		id, err := e.storeChunk(ctx, data)
-		if err != nil {
-			return fmt.Errorf("error storing chunks: %w", err)
+// chunk is Data from the Parser along with its position in the stream so it can be stored in
+// the same order that it was parsed, regardless of how many workers are used
+type chunk struct {
+	seq       int
+	data      godocrag.Data
+	hash      string
+	status    chunkStatus
+	embedding []float32
+}
+
+// chunkStatus compares a chunk to what is already stored
+type chunkStatus int
+
+const (
+	chunkNew chunkStatus = iota
+	chunkChanged
+	chunkUnchanged
+	// chunkFailed could not be embedded, so it is not stored
+	chunkFailed
+)
+
+// Stats reports how many chunks were embedded by Embed
+type Stats struct {
+	New       int
+	Changed   int
+	Unchanged int
+	Pruned    int
+	// Failed chunks could not be embedded and are embedded again by the next run
+	Failed int
+}
+
+// Embed generates embeddings for parsed Data concurrently and stores the results in order. Chunks
+// that are unchanged since the last run with the same model and dimension are not embedded again.
+// Afterwards, stored chunks that no longer exist in the parsed packages are deleted
+func (e Embedder) Embed(ctx context.Context) (Stats, error) {
+	dimension, err := embedding.Dimension(ctx, e.embedder)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	g, groupCtx := errgroup.WithContext(ctx)
+
+	batches := make(chan []chunk)
+	embedded := make(chan chunk)
+
+	// Read from the Parser, number the chunks, and group them into batches. The Parser stops
+	// when the context is cancelled, which happens if any worker fails
+	g.Go(func() error {
+		defer close(batches)
+
+		send := func(batch []chunk) bool {
+			select {
+			case batches <- batch:
+				return true
+			case <-groupCtx.Done():
+				return false
+			}
+		}
+
+		var batch []chunk
+		seq := 0
+		for data := range e.p.Parse(groupCtx) {
+			batch = append(batch, chunk{seq: seq, data: data})
+			seq++
+
+			if len(batch) < e.batchSize {
+				continue
+			}
+			if !send(batch) {
+				return groupCtx.Err()
+			}
+			batch = nil
 		}
 
-		// Get embeddings for each chunk and store them
-		err = e.processChunkEmbedding(ctx, id, data)
-		if err != nil {
-			return fmt.Errorf("error processing chunks: %w", err)
+		if len(batch) > 0 && !send(batch) {
+			return groupCtx.Err()
 		}
+		return groupCtx.Err()
+	})
+
+	var workers sync.WaitGroup
+	for range e.workers {
+		workers.Add(1)
+		g.Go(func() error {
+			defer workers.Done()
+
+			for batch := range batches {
+				var toEmbed []*chunk
+				for i := range batch {
+					err := e.setStatus(groupCtx, &batch[i], dimension)
+					if err != nil {
+						return fmt.Errorf("error checking stored chunks: %w", err)
+					}
+					if batch[i].status != chunkUnchanged {
+						toEmbed = append(toEmbed, &batch[i])
+					}
+				}
+
+				if len(toEmbed) > 0 {
+					err := e.embedBatch(groupCtx, toEmbed)
+					if err != nil {
+						return fmt.Errorf("error processing chunks: %w", err)
+					}
+				}
+
+				for _, c := range batch {
+					select {
+					case embedded <- c:
+					case <-groupCtx.Done():
+						return groupCtx.Err()
+					}
+				}
+			}
+			return nil
+		})
 	}
 
-	return e.p.Error()
-}
+	go func() {
+		workers.Wait()
+		close(embedded)
+	}()
 
-func (e Embedder) storeChunk(ctx context.Context, data godocrag.Data) (int, error) {
-	var id int
-	err := e.db.QueryRowContext(ctx,
-		`INSERT INTO comment_data (data, package, filename, symbol, type)
-			VALUES ($1, $2, $3, $4, $5)
-			ON CONFLICT (package, filename, symbol, type)
-			DO UPDATE SET
-				package = EXCLUDED.package,
-				filename = EXCLUDED.filename,
-				symbol = EXCLUDED.symbol,
-				type = EXCLUDED.type
-			RETURNING id`,
-		data.String(), data.Package, data.Filename, data.Symbol, data.Type,
-	).Scan(&id)
+	// Store chunks in the order they were parsed so the results are deterministic
+	var stats Stats
+	seen := seenChunks{}
+	g.Go(func() error {
+		pending := map[int]chunk{}
+		next := 0
+		for c := range embedded {
+			pending[c.seq] = c
+			for {
+				c, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+
+				switch c.status {
+				case chunkNew:
+					stats.New++
+				case chunkChanged:
+					stats.Changed++
+				case chunkUnchanged:
+					stats.Unchanged++
+				case chunkFailed:
+					stats.Failed++
+					// Keep any previously stored version instead of pruning it
+					seen.add(c.data)
+					continue
+				}
+
+				if err := e.storeChunk(groupCtx, c); err != nil {
+					return err
+				}
+				seen.add(c.data)
+			}
+		}
+		return nil
+	})
+
+	if err := g.Wait(); err != nil {
+		return stats, err
+	}
+
+	parseErr := e.p.Error()
+	pruned, err := e.prune(ctx, seen, parseErr, false)
+	stats.Pruned = len(pruned)
 	if err != nil {
-		return 0, fmt.Errorf("failed to insert chunk: %v", err)
+		return stats, err
 	}
 
-	return id, nil
+	return stats, parseErr
 }
 
-func (e Embedder) processChunkEmbedding(ctx context.Context, chunkID int, data godocrag.Data) error {
-	req := api.EmbedRequest{
-		Model: e.model,
-		Input: data.String(),
+// setStatus compares the chunk's content hash with the hash of the stored embedding for the
+// embedding model and dimension. A chunk is changed if it exists but was not embedded by the model
+// with the dimension
+func (e Embedder) setStatus(ctx context.Context, c *chunk, dimension int) error {
+	c.hash = c.data.Hash()
+
+	stored, ok, err := e.store.GetChunk(ctx, store.Key{
+		Package: c.data.Package,
+		Symbol:  c.data.Symbol,
+		Type:    c.data.Type,
+	}, e.embedder.Model(), dimension)
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		c.status = chunkNew
+		return nil
 	}
 
-	resp, err := e.ollamaClient.Embed(ctx, &req)
+	c.status = chunkChanged
+	if stored.EmbeddingHash == c.hash {
+		c.status = chunkUnchanged
+	}
+
+	return nil
+}
+
+// storeChunk upserts the chunk so metadata such as the filename and line are always up to date. The
+// embedding is only stored if the chunk was embedded
+func (e Embedder) storeChunk(ctx context.Context, c chunk) error {
+	id, err := e.store.UpsertChunk(ctx, c.data, c.hash)
 	if err != nil {
-		return fmt.Errorf("failed to generate embedding for chunk: %w", err)
+		return fmt.Errorf("error storing chunks: %w", err)
 	}
 
-	if len(resp.Embeddings) != 1 {
-		return fmt.Errorf("unexpected number of embeddings returned for chunk")
+	if c.status == chunkUnchanged {
+		return nil
 	}
 
-	err = e.insertEmbedding(ctx, chunkID, resp.Embeddings[0])
+	err = e.store.UpsertEmbedding(ctx, id, e.embedder.Model(), c.hash, c.embedding)
 	if err != nil {
 		return fmt.Errorf("failed to store embedding for chunk: %w", err)
 	}
@@ -94,20 +262,63 @@ func (e Embedder) processChunkEmbedding(ctx context.Context, chunkID int, data g
 	return nil
 }
 
-func (e Embedder) insertEmbedding(ctx context.Context, chunkID int, vector []float32) error {
-	// Convert vector to Postgres array literal
-	strVals := make([]string, len(vector))
-	for i, v := range vector {
-		strVals[i] = fmt.Sprintf("%f", v)
-	}
-	arrayLiteral := fmt.Sprintf("[%s]", strings.Join(strVals, ","))
-
-	_, err := e.db.ExecContext(ctx,
-		`INSERT INTO embeddings (id, embedding)
-		VALUES ($1, $2)
-		ON CONFLICT (id) DO UPDATE SET
-			embedding = EXCLUDED.embedding`,
-		chunkID, arrayLiteral,
-	)
-	return err
+// embedBatch generates embeddings for all chunks in the batch with a single request. If the
+// request fails, each chunk is retried individually so one problematic chunk does not prevent
+// the rest of the batch from being embedded. Chunks that still fail are skipped and marked as
+// failed. An error is only returned if the context is cancelled
+func (e Embedder) embedBatch(ctx context.Context, batch []*chunk) error {
+	if len(batch) == 1 {
+		return e.embedChunk(ctx, batch[0])
+	}
+
+	input := make([]string, len(batch))
+	for i, c := range batch {
+		input[i] = c.data.String()
+	}
+
+	embeddings, err := e.embedder.Embed(ctx, input)
+	if err == nil {
+		for i := range batch {
+			batch[i].embedding = embeddings[i]
+		}
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	log.Printf("failed to generate embeddings for batch, retrying %d chunks individually: %v", len(batch), err)
+	for _, c := range batch {
+		if err := e.embedChunk(ctx, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// embedChunk generates the embedding for a single chunk. If it fails, the chunk is marked as
+// failed and skipped
+func (e Embedder) embedChunk(ctx context.Context, c *chunk) error {
+	var err error
+	c.embedding, err = e.getEmbedding(ctx, c.data)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	log.Printf("skipping %s %s.%s: %v", c.data.Type, c.data.Package, c.data.Symbol, err)
+	c.status = chunkFailed
+	return nil
+}
+
+func (e Embedder) getEmbedding(ctx context.Context, data godocrag.Data) ([]float32, error) {
+	embeddings, err := e.embedder.Embed(ctx, []string{data.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate embedding for chunk: %w", err)
+	}
+
+	return embeddings[0], nil
 }
@@ -0,0 +1,274 @@
+package embedder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	godocrag "godoc-rag"
+	"godoc-rag/embedding"
+	"godoc-rag/parser"
+	"godoc-rag/store"
+)
+
+// writeModule writes the files to a module in a temporary directory and changes to it
+func writeModule(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	files["go.mod"] = "module example.com/upsert\n\ngo 1.21\n"
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func TestEmbedUpsert(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	vs := store.NewMemory()
+	hash := embedding.NewHash(64)
+
+	embed := func(t *testing.T) Stats {
+		t.Helper()
+
+		stats, err := New(vs, hash, parser.New("./...", false), 2, 2).Embed(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return stats
+	}
+
+	getChunk := func(t *testing.T, symbol string) store.Chunk {
+		t.Helper()
+
+		c, ok, err := vs.GetChunk(context.Background(), store.Key{
+			Package: "example.com/upsert",
+			Symbol:  symbol,
+			Type:    "function",
+		}, hash.Model(), hash.Dimension())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !ok {
+			t.Fatalf("missing chunk for %s", symbol)
+		}
+		return c
+	}
+
+	writeModule(t, dir, map[string]string{
+		"a.go": `// Package upsert is used to test embedding
+package upsert
+
+// Add returns the sum of a and b
+func Add(a, b int) int { return a + b }
+
+// Sub returns the difference of a and b
+func Sub(a, b int) int { return a - b }
+`,
+	})
+
+	stats := embed(t)
+	if stats.New != 3 || stats.Changed != 0 || stats.Unchanged != 0 {
+		t.Fatalf("expected 3 new chunks but got %+v", stats)
+	}
+
+	t.Run("Unchanged", func(t *testing.T) {
+		stats := embed(t)
+		if stats.New != 0 || stats.Changed != 0 || stats.Unchanged != 3 {
+			t.Errorf("expected 3 unchanged chunks but got %+v", stats)
+		}
+	})
+
+	t.Run("ChangeDocComment", func(t *testing.T) {
+		writeModule(t, dir, map[string]string{
+			"a.go": `// Package upsert is used to test embedding
+package upsert
+
+// Add returns a plus b
+func Add(a, b int) int { return a + b }
+
+// Sub returns the difference of a and b
+func Sub(a, b int) int { return a - b }
+`,
+		})
+
+		stats := embed(t)
+		if stats.New != 0 || stats.Changed != 1 || stats.Unchanged != 2 {
+			t.Errorf("expected 1 changed chunk but got %+v", stats)
+		}
+
+		c := getChunk(t, "Add")
+		if !strings.Contains(c.Data.Data, "Add returns a plus b") {
+			t.Errorf("expected updated doc comment but got %q", c.Data.Data)
+		}
+		if c.EmbeddingHash != c.Hash {
+			t.Errorf("expected embedding for the updated chunk but got hash %q for %q", c.EmbeddingHash, c.Hash)
+		}
+	})
+
+	t.Run("MoveToFile", func(t *testing.T) {
+		writeModule(t, dir, map[string]string{
+			"a.go": `// Package upsert is used to test embedding
+package upsert
+
+// Add returns a plus b
+func Add(a, b int) int { return a + b }
+`,
+			"b.go": `package upsert
+
+// Sub returns the difference of a and b
+func Sub(a, b int) int { return a - b }
+`,
+		})
+
+		stats := embed(t)
+		if stats.New != 0 || stats.Changed != 0 || stats.Unchanged != 3 || stats.Pruned != 0 {
+			t.Errorf("expected 3 unchanged chunks but got %+v", stats)
+		}
+
+		c := getChunk(t, "Sub")
+		if filepath.Base(c.Filename) != "b.go" {
+			t.Errorf("expected b.go but got %q", c.Filename)
+		}
+
+		chunks, err := vs.List(context.Background(), "example.com/upsert")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(chunks) != 3 {
+			t.Errorf("expected 3 stored chunks but got %d", len(chunks))
+		}
+	})
+}
+
+// staticParser sends the same Data every time it is parsed
+type staticParser []godocrag.Data
+
+func (p staticParser) Parse(ctx context.Context) <-chan godocrag.Data {
+	out := make(chan godocrag.Data)
+	go func() {
+		defer close(out)
+		for _, d := range p {
+			select {
+			case out <- d:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (p staticParser) Error() error {
+	return nil
+}
+
+func generateData(n int) staticParser {
+	result := make(staticParser, n)
+	for i := range result {
+		result[i] = godocrag.Data{
+			Type:     "function",
+			Symbol:   fmt.Sprintf("Func%d", i),
+			Package:  fmt.Sprintf("example.com/pkg%d", i%10),
+			Filename: "file.go",
+			Data:     fmt.Sprintf("Func%d does something with %d values", i, i),
+		}
+	}
+	return result
+}
+
+// newOpenAIServer returns an OpenAI-compatible embeddings API that uses the Hash embedder and waits
+// before responding like a model server would
+func newOpenAIServer(t testing.TB, delay time.Duration) *httptest.Server {
+	t.Helper()
+
+	hash := embedding.NewHash(64)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		embeddings, _ := hash.Embed(r.Context(), req.Input)
+		time.Sleep(delay)
+
+		type item struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		var resp struct {
+			Data []item `json:"data"`
+		}
+		for i, e := range embeddings {
+			resp.Data = append(resp.Data, item{i, e})
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestEmbedOrder(t *testing.T) {
+	server := newOpenAIServer(t, time.Millisecond)
+	data := generateData(200)
+
+	list := func(t *testing.T, workers int) []store.Chunk {
+		t.Helper()
+
+		vs := store.NewMemory()
+		e := New(vs, embedding.NewOpenAI(server.URL, "", "test", 0), data, workers, 3)
+		if _, err := e.Embed(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var result []store.Chunk
+		for i := range 10 {
+			chunks, err := vs.List(context.Background(), fmt.Sprintf("example.com/pkg%d", i))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			result = append(result, chunks...)
+		}
+		return result
+	}
+
+	expected := list(t, 1)
+	actual := list(t, 8)
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %d chunks but got %d", len(expected), len(actual))
+	}
+	for i := range expected {
+		if actual[i].ID != expected[i].ID || actual[i].Symbol != expected[i].Symbol {
+			t.Errorf("expected chunk %d to be %d %s but got %d %s", i, expected[i].ID, expected[i].Symbol, actual[i].ID, actual[i].Symbol)
+		}
+	}
+}
+
+func BenchmarkEmbed(b *testing.B) {
+	server := newOpenAIServer(b, time.Millisecond)
+	data := generateData(500)
+
+	for _, workers := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for b.Loop() {
+				e := New(store.NewMemory(), embedding.NewOpenAI(server.URL, "", "test", 0), data, workers, 10)
+				if _, err := e.Embed(context.Background()); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package embedder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	godocrag "godoc-rag"
+)
+
+// chunkKey is the unique key for a stored chunk within a package
+type chunkKey struct {
+	symbol string
+	typ    string
+}
+
+// seenChunks tracks the keys of parsed chunks for each package
+type seenChunks map[string]map[chunkKey]bool
+
+func (s seenChunks) add(data godocrag.Data) {
+	if s[data.Package] == nil {
+		s[data.Package] = map[chunkKey]bool{}
+	}
+	s[data.Package][chunkKey{data.Symbol, data.Type}] = true
+}
+
+// Prune parses the packages without embedding and deletes stored chunks in those packages for
+// symbols that no longer exist in the source. If dryRun is true, the chunks are only listed
+func (e Embedder) Prune(ctx context.Context, dryRun bool) ([]godocrag.Data, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	seen := seenChunks{}
+	for data := range e.p.Parse(ctx) {
+		seen.add(data)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	parseErr := e.p.Error()
+	pruned, err := e.prune(ctx, seen, parseErr, dryRun)
+	if err != nil {
+		return pruned, err
+	}
+
+	return pruned, parseErr
+}
+
+// prune finds stored chunks that were not seen in each parsed package. Packages with parse errors
+// are skipped since their chunks could be missing because a file was skipped
+func (e Embedder) prune(ctx context.Context, seen seenChunks, parseErr error, dryRun bool) ([]godocrag.Data, error) {
+	if parseErr != nil {
+		var pkgErrs interface{ Packages() []string }
+		if !errors.As(parseErr, &pkgErrs) {
+			return nil, nil
+		}
+		for _, pkg := range pkgErrs.Packages() {
+			delete(seen, pkg)
+		}
+	}
+
+	var result []godocrag.Data
+	for _, pkg := range slices.Sorted(maps.Keys(seen)) {
+		stored, err := e.store.List(ctx, pkg)
+		if err != nil {
+			return result, err
+		}
+
+		var ids []int
+		for _, c := range stored {
+			if seen[pkg][chunkKey{c.Symbol, c.Type}] {
+				continue
+			}
+			ids = append(ids, c.ID)
+			result = append(result, c.Data)
+		}
+
+		if dryRun || len(ids) == 0 {
+			continue
+		}
+		if err := e.store.Delete(ctx, ids...); err != nil {
+			return result, fmt.Errorf("failed to delete chunks from %s: %w", pkg, err)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,181 @@
+package embedder
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	godocrag "godoc-rag"
+	"godoc-rag/embedding"
+	"godoc-rag/parser"
+	"godoc-rag/store"
+)
+
+// errParser sends the Data and then returns the error
+type errParser struct {
+	staticParser
+	err error
+}
+
+func (p errParser) Error() error {
+	return p.err
+}
+
+// failingEmbedder fails to embed any input containing the text
+type failingEmbedder struct {
+	embedding.Embedder
+	text string
+}
+
+func (e failingEmbedder) Embed(ctx context.Context, input []string) ([][]float32, error) {
+	for _, in := range input {
+		if strings.Contains(in, e.text) {
+			return nil, errors.New("failed to embed")
+		}
+	}
+	return e.Embedder.Embed(ctx, input)
+}
+
+func pruneTestData(pkg string, symbols ...string) staticParser {
+	var result staticParser
+	for _, symbol := range symbols {
+		result = append(result, godocrag.Data{
+			Type:    "function",
+			Symbol:  symbol,
+			Package: pkg,
+			Data:    symbol + " does something",
+		})
+	}
+	return result
+}
+
+// storedSymbols returns the symbols of the stored chunks in the package
+func storedSymbols(t *testing.T, vs store.VectorStore, pkg string) []string {
+	t.Helper()
+
+	chunks, err := vs.List(context.Background(), pkg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var result []string
+	for _, c := range chunks {
+		result = append(result, c.Symbol)
+	}
+	return result
+}
+
+func embedTestData(t *testing.T, vs store.VectorStore, embedder embedding.Embedder, p Parser) Stats {
+	t.Helper()
+
+	stats, err := New(vs, embedder, p, 2, 2).Embed(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return stats
+}
+
+func TestEmbedPrune(t *testing.T) {
+	vs := store.NewMemory()
+	hash := embedding.NewHash(64)
+	embedTestData(t, vs, hash, pruneTestData("example.com/a", "A", "B", "C"))
+
+	stats := embedTestData(t, vs, hash, pruneTestData("example.com/a", "A", "C"))
+	if stats.Pruned != 1 || stats.Unchanged != 2 {
+		t.Errorf("expected 1 pruned and 2 unchanged chunks but got %+v", stats)
+	}
+	if symbols := storedSymbols(t, vs, "example.com/a"); !slices.Equal(symbols, []string{"A", "C"}) {
+		t.Errorf("expected [A C] but got %v", symbols)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	vs := store.NewMemory()
+	hash := embedding.NewHash(64)
+	embedTestData(t, vs, hash, pruneTestData("example.com/a", "A", "B", "C"))
+
+	e := New(vs, hash, pruneTestData("example.com/a", "A"), 1, 1)
+
+	t.Run("DryRun", func(t *testing.T) {
+		pruned, err := e.Prune(context.Background(), true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if symbols := symbolsOf(pruned); !slices.Equal(symbols, []string{"B", "C"}) {
+			t.Errorf("expected [B C] to be pruned but got %v", symbols)
+		}
+		if symbols := storedSymbols(t, vs, "example.com/a"); !slices.Equal(symbols, []string{"A", "B", "C"}) {
+			t.Errorf("expected dry run to keep [A B C] but got %v", symbols)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		pruned, err := e.Prune(context.Background(), false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if symbols := symbolsOf(pruned); !slices.Equal(symbols, []string{"B", "C"}) {
+			t.Errorf("expected [B C] to be pruned but got %v", symbols)
+		}
+		if symbols := storedSymbols(t, vs, "example.com/a"); !slices.Equal(symbols, []string{"A"}) {
+			t.Errorf("expected [A] but got %v", symbols)
+		}
+	})
+}
+
+func TestPruneParseErrors(t *testing.T) {
+	vs := store.NewMemory()
+	hash := embedding.NewHash(64)
+	embedTestData(t, vs, hash, append(pruneTestData("example.com/a", "A", "B"), pruneTestData("example.com/b", "X", "Y")...))
+
+	// example.com/a has a file that could not be parsed, so B could be in that file
+	parseErr := parser.ParseErrors{{Package: "example.com/a", Filename: "b.go", Err: errors.New("syntax error")}}
+	p := errParser{append(pruneTestData("example.com/a", "A"), pruneTestData("example.com/b", "X")...), parseErr}
+
+	pruned, err := New(vs, hash, p, 1, 1).Prune(context.Background(), false)
+	var parseErrs parser.ParseErrors
+	if !errors.As(err, &parseErrs) {
+		t.Errorf("expected ParseErrors but got %v", err)
+	}
+	if symbols := symbolsOf(pruned); !slices.Equal(symbols, []string{"Y"}) {
+		t.Errorf("expected [Y] to be pruned but got %v", symbols)
+	}
+	if symbols := storedSymbols(t, vs, "example.com/a"); !slices.Equal(symbols, []string{"A", "B"}) {
+		t.Errorf("expected [A B] to be kept but got %v", symbols)
+	}
+}
+
+func TestEmbedFailedChunkKept(t *testing.T) {
+	vs := store.NewMemory()
+	hash := embedding.NewHash(64)
+	embedTestData(t, vs, hash, pruneTestData("example.com/a", "A", "B"))
+
+	changed := pruneTestData("example.com/a", "A", "B")
+	changed[1].Data = "B does something else"
+
+	stats := embedTestData(t, vs, failingEmbedder{hash, "something else"}, changed)
+	if stats.Failed != 1 || stats.Unchanged != 1 || stats.Pruned != 0 {
+		t.Errorf("expected 1 failed and 1 unchanged chunk but got %+v", stats)
+	}
+
+	c, ok, err := vs.GetChunk(context.Background(), store.Key{Package: "example.com/a", Symbol: "B", Type: "function"}, hash.Model(), hash.Dimension())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected failed chunk to be kept")
+	}
+	if strings.Contains(c.Data.Data, "something else") || c.EmbeddingHash != c.Hash {
+		t.Errorf("expected the previous version of the failed chunk but got %q", c.Data.Data)
+	}
+}
+
+func symbolsOf(data []godocrag.Data) []string {
+	var result []string
+	for _, d := range data {
+		result = append(result, d.Symbol)
+	}
+	return result
+}
@@ -0,0 +1,62 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ollama/ollama/api"
+)
+
+// Embedder generates vector embeddings for text using a specific model
+type Embedder interface {
+	// Embed returns an embedding for each input, in the same order as the input
+	Embed(ctx context.Context, input []string) ([][]float32, error)
+	// Model is the name of the model used to generate embeddings
+	Model() string
+}
+
+// Dimension returns the dimension of the embeddings from the Embedder. If the Embedder does not
+// implement Dimension() int or returns 0, a short input is embedded to find it
+func Dimension(ctx context.Context, e Embedder) (int, error) {
+	if d, ok := e.(interface{ Dimension() int }); ok && d.Dimension() > 0 {
+		return d.Dimension(), nil
+	}
+
+	embeddings, err := e.Embed(ctx, []string{"dimension"})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get embedding dimension for model %q: %w", e.Model(), err)
+	}
+	return len(embeddings[0]), nil
+}
+
+// Ollama implements Embedder using the Ollama API
+type Ollama struct {
+	client *api.Client
+	model  string
+}
+
+var _ Embedder = Ollama{}
+
+func NewOllama(client *api.Client, model string) Ollama {
+	return Ollama{client, model}
+}
+
+func (o Ollama) Embed(ctx context.Context, input []string) ([][]float32, error) {
+	resp, err := o.client.Embed(ctx, &api.EmbedRequest{
+		Model: o.model,
+		Input: input,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Embeddings) != len(input) {
+		return nil, fmt.Errorf("expected %d embeddings but got %d", len(input), len(resp.Embeddings))
+	}
+
+	return resp.Embeddings, nil
+}
+
+func (o Ollama) Model() string {
+	return o.model
+}
@@ -0,0 +1,82 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	godocrag "godoc-rag"
+)
+
+// Hash implements Embedder without a model server by hashing words and character n-grams into a
+// vector with a fixed dimension. The embeddings are deterministic and only capture lexical
+// similarity, so this is intended for testing and CI
+type Hash struct {
+	dimension int
+}
+
+var _ Embedder = Hash{}
+
+const hashNGramSize = 3
+
+func NewHash(dimension int) Hash {
+	return Hash{dimension: max(dimension, 1)}
+}
+
+func (h Hash) Embed(_ context.Context, input []string) ([][]float32, error) {
+	result := make([][]float32, len(input))
+	for i, text := range input {
+		result[i] = h.embed(text)
+	}
+	return result, nil
+}
+
+func (h Hash) Dimension() int {
+	return h.dimension
+}
+
+// Model includes the dimension so embeddings with different dimensions are not compared
+func (h Hash) Model() string {
+	return fmt.Sprintf("hash-%d", h.dimension)
+}
+
+func (h Hash) embed(text string) []float32 {
+	vector := make([]float32, h.dimension)
+	for _, word := range godocrag.Tokenize(text) {
+		// Whole words are weighted higher than n-grams so exact identifiers match best
+		h.add(vector, "w:"+word, 2)
+
+		padded := "^" + word + "$"
+		for i := 0; i+hashNGramSize <= len(padded); i++ {
+			h.add(vector, "n:"+padded[i:i+hashNGramSize], 1)
+		}
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+
+	return vector
+}
+
+// add hashes the feature to an index in the vector. The hash also determines the sign so
+// collisions tend to cancel out instead of accumulating
+func (h Hash) add(vector []float32, feature string, weight float32) {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(feature))
+	sum := hasher.Sum64()
+
+	if sum&1 == 1 {
+		weight = -weight
+	}
+	vector[(sum>>1)%uint64(h.dimension)] += weight
+}
@@ -0,0 +1,70 @@
+package embedding
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+)
+
+func dot(a, b []float32) float64 {
+	var result float64
+	for i := range a {
+		result += float64(a[i]) * float64(b[i])
+	}
+	return result
+}
+
+func TestHashEmbed(t *testing.T) {
+	h := NewHash(128)
+	if h.Model() != "hash-128" || h.Dimension() != 128 {
+		t.Errorf("unexpected model %q with dimension %d", h.Model(), h.Dimension())
+	}
+
+	input := []string{
+		"ReadFile reads the named file and returns the contents",
+		"ReadFile reads the named file and returns the contents",
+		"ReadDir reads the named directory",
+		"Sqrt returns the square root of x",
+		"",
+	}
+	embeddings, err := h.Embed(context.Background(), input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(embeddings) != len(input) {
+		t.Fatalf("expected %d embeddings but got %d", len(input), len(embeddings))
+	}
+
+	t.Run("Deterministic", func(t *testing.T) {
+		again, err := NewHash(128).Embed(context.Background(), input[:1])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !slices.Equal(embeddings[0], again[0]) || !slices.Equal(embeddings[0], embeddings[1]) {
+			t.Error("expected the same embedding for the same text")
+		}
+	})
+
+	t.Run("Normalized", func(t *testing.T) {
+		for i, e := range embeddings[:4] {
+			if len(e) != 128 {
+				t.Errorf("expected dimension 128 but got %d", len(e))
+			}
+			if norm := math.Sqrt(dot(e, e)); math.Abs(norm-1) > 1e-5 {
+				t.Errorf("expected unit vector for input %d but got norm %f", i, norm)
+			}
+		}
+		if dot(embeddings[4], embeddings[4]) != 0 {
+			t.Error("expected zero vector for empty input")
+		}
+	})
+
+	t.Run("LexicalSimilarity", func(t *testing.T) {
+		similar := dot(embeddings[0], embeddings[2])
+		unrelated := dot(embeddings[0], embeddings[3])
+		if similar <= unrelated {
+			t.Errorf("expected similar text to score higher: %f <= %f", similar, unrelated)
+		}
+	})
+}
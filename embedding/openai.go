@@ -0,0 +1,121 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OpenAI implements Embedder using the OpenAI-compatible /v1/embeddings API, which is also
+// supported by servers like vLLM and llama.cpp
+type OpenAI struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	model   string
+	// dimensions is sent with requests for models that support shortened embeddings
+	dimensions int
+}
+
+var _ Embedder = OpenAI{}
+
+// NewOpenAI creates an Embedder for the API at baseURL, which includes the version like
+// http://localhost:8000/v1. The apiKey is optional. If dimensions is set, it is sent with each
+// request so models that support it return embeddings with that dimension
+func NewOpenAI(baseURL, apiKey, model string, dimensions int) OpenAI {
+	return OpenAI{
+		client:     http.DefaultClient,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+		dimensions: dimensions,
+	}
+}
+
+type openAIRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type openAIResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (o OpenAI) Embed(ctx context.Context, input []string) ([][]float32, error) {
+	body, err := json.Marshal(openAIRequest{Model: o.model, Input: input, Dimensions: o.dimensions})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var embedResp openAIResponse
+	if err := json.Unmarshal(respBody, &embedResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response with status %d: %w", resp.StatusCode, err)
+	}
+	if embedResp.Error != nil {
+		return nil, fmt.Errorf("error from embeddings API: %s", embedResp.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from embeddings API: %d", resp.StatusCode)
+	}
+
+	if len(embedResp.Data) != len(input) {
+		return nil, fmt.Errorf("expected %d embeddings but got %d", len(input), len(embedResp.Data))
+	}
+
+	// Use the index from the response since the order is not guaranteed
+	result := make([][]float32, len(input))
+	for _, d := range embedResp.Data {
+		if d.Index < 0 || d.Index >= len(input) {
+			return nil, fmt.Errorf("unexpected embedding index %d", d.Index)
+		}
+		if result[d.Index] != nil {
+			return nil, fmt.Errorf("duplicate embedding index %d", d.Index)
+		}
+		if len(d.Embedding) == 0 {
+			return nil, fmt.Errorf("empty embedding for index %d", d.Index)
+		}
+		result[d.Index] = d.Embedding
+	}
+
+	return result, nil
+}
+
+func (o OpenAI) Model() string {
+	return o.model
+}
+
+// Dimension is the requested dimension, or 0 if the model's default is used
+func (o OpenAI) Dimension() int {
+	return o.dimensions
+}
@@ -0,0 +1,86 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestOpenAIEmbed(t *testing.T) {
+	var req openAIRequest
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/embeddings" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Respond out of order to check that the index is used
+		w.Write([]byte(`{"data": [
+			{"index": 1, "embedding": [0, 1]},
+			{"index": 0, "embedding": [1, 0]}
+		]}`))
+	}))
+	defer server.Close()
+
+	o := NewOpenAI(server.URL+"/v1/", "secret", "text-embedding-3-small", 2)
+	embeddings, err := o.Embed(context.Background(), []string{"first", "second"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if req.Model != "text-embedding-3-small" {
+		t.Errorf("expected model text-embedding-3-small but got %q", req.Model)
+	}
+	if !slices.Equal(req.Input, []string{"first", "second"}) {
+		t.Errorf("expected input [first second] but got %v", req.Input)
+	}
+	if req.Dimensions != 2 {
+		t.Errorf("expected dimensions 2 but got %d", req.Dimensions)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("expected bearer auth but got %q", auth)
+	}
+
+	if len(embeddings) != 2 || !slices.Equal(embeddings[0], []float32{1, 0}) || !slices.Equal(embeddings[1], []float32{0, 1}) {
+		t.Errorf("expected embeddings in input order but got %v", embeddings)
+	}
+}
+
+func TestOpenAIEmbedErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+	}{
+		{"APIError", http.StatusBadRequest, `{"error": {"message": "invalid model"}}`},
+		{"Status", http.StatusInternalServerError, `{}`},
+		{"MissingEmbeddings", http.StatusOK, `{"data": [{"index": 0, "embedding": [1, 0]}]}`},
+		{"InvalidIndex", http.StatusOK, `{"data": [{"index": 0, "embedding": [1, 0]}, {"index": 2, "embedding": [0, 1]}]}`},
+		{"DuplicateIndex", http.StatusOK, `{"data": [{"index": 0, "embedding": [1, 0]}, {"index": 0, "embedding": [0, 1]}]}`},
+		{"EmptyEmbedding", http.StatusOK, `{"data": [{"index": 0, "embedding": [1, 0]}, {"index": 1, "embedding": []}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			_, err := NewOpenAI(server.URL, "", "model", 0).Embed(context.Background(), []string{"first", "second"})
+			if err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 // Package example demonstrates common Go patterns with documentation
 package example
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // Person represents an individual with basic information.
 // It demonstrates a simple struct with various field types.
@@ -43,7 +46,7 @@ func (p *Person) Greet() string {
 // This demonstrates error handling in Go.
 func (p *Person) UpdateEmail(newEmail string) error {
 	if newEmail == "" {
-		return fmt.Errorf("email cannot be empty")
+		return ErrEmptyEmail
 	}
 	p.Email = newEmail
 	return nil
@@ -60,3 +63,22 @@ func (p *Person) HasBirthday() {
 func PrintGreeting(g Greeter) {
 	fmt.Println(g.Greet())
 }
+
+// Status describes the current state of a Person's account.
+type Status int
+
+// Account statuses
+const (
+	// StatusActive is used for accounts that are currently in use
+	StatusActive Status = iota
+	// StatusSuspended is used for accounts that are temporarily disabled
+	StatusSuspended
+	// StatusClosed is used for accounts that have been permanently closed
+	StatusClosed
+)
+
+// DefaultGreeting is the greeting used when a Person has no name
+const DefaultGreeting = "Hello!"
+
+// ErrEmptyEmail is returned when attempting to set an empty email
+var ErrEmptyEmail = errors.New("email cannot be empty")
@@ -0,0 +1,20 @@
+package example_test
+
+import (
+	"fmt"
+
+	"godoc-rag/example"
+)
+
+// ExampleNewPerson creates a Person and prints their details
+func ExampleNewPerson() {
+	p := example.NewPerson("Alice", 30, "alice@example.com")
+	fmt.Println(p.Name, p.Age)
+	// Output: Alice 30
+}
+
+func ExamplePerson_Greet() {
+	p := example.NewPerson("Bob", 25, "bob@example.com")
+	fmt.Println(p.Greet())
+	// Output: Hello, my name is Bob!
+}
@@ -1,20 +1,20 @@
 module godoc-rag
 
-go 1.24.0
+go 1.25.0
 
 require (
 	github.com/lib/pq v1.10.9
 	github.com/modelcontextprotocol/go-sdk v0.5.0
 	github.com/ollama/ollama v0.11.8
 	github.com/urfave/cli/v3 v3.4.1
-	golang.org/x/tools v0.34.0
+	golang.org/x/sync v0.20.0
+	golang.org/x/tools v0.44.0
 )
 
 require (
 	github.com/google/jsonschema-go v0.2.3 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
 	golang.org/x/crypto v0.36.0 // indirect
-	golang.org/x/mod v0.25.0 // indirect
-	golang.org/x/sync v0.15.0 // indirect
-	golang.org/x/sys v0.33.0 // indirect
+	golang.org/x/mod v0.35.0 // indirect
+	golang.org/x/sys v0.43.0 // indirect
 )
@@ -2,6 +2,7 @@ package mcp
 
 import (
 	"context"
+	"errors"
 	"iter"
 	"net/http"
 
@@ -13,28 +14,33 @@ import (
 // Loader enables the MCP Server to do semantic searches on the embedded data
 type Loader interface {
 	// SemanticSearch is used to search embedded data
-	SemanticSearch(ctx context.Context, query string, limit int) (iter.Seq[godocrag.Data], func() error, error)
+	SemanticSearch(ctx context.Context, query string, opts godocrag.SearchOptions) (iter.Seq[godocrag.Data], func() error, error)
 }
 
 // Server implements the MCP Server for RAG
 type Server struct {
-	loader Loader
-	stdio  bool
-	addr   string
-	server *mcp.Server
+	loader   Loader
+	stdio    bool
+	addr     string
+	defaults godocrag.SearchOptions
+	server   *mcp.Server
 }
 
-func NewServer(loader Loader, stdio bool, addr string) Server {
+// NewServer creates a Server. The defaults are used for search options that are not set in the
+// tool input
+func NewServer(loader Loader, stdio bool, addr string, defaults godocrag.SearchOptions) Server {
 	s := Server{
-		loader: loader,
-		stdio:  stdio,
-		addr:   addr,
+		loader:   loader,
+		stdio:    stdio,
+		addr:     addr,
+		defaults: defaults,
 		server: mcp.NewServer(&mcp.Implementation{Name: "godoc-rag", Version: "v1.0.0"}, &mcp.ServerOptions{
 			Instructions: `This MCP server provides semantic search capabilities over Go
 package documentation. It parses documentation from both internal projects
 and external Go modules, generates vector embeddings, and stores them in
 pgvector. When queried, the server retrieves the most relevant doc snippets,
-functions, or API descriptions based on semantic similarity.
+functions, or API descriptions based on semantic similarity combined with
+keyword matches, so exact identifiers like UpdateEmail or ErrNotFound are found.
 
 Use this server to:
 - Look up Go functions, types, methods, and usage examples.
@@ -49,10 +55,11 @@ semantic search results from the indexed documentation.`,
 	return s
 }
 
-func (s Server) Run() error {
+// Run serves until the context is cancelled
+func (s Server) Run(ctx context.Context) error {
 	if s.stdio {
-		err := s.server.Run(context.Background(), &mcp.StdioTransport{})
-		if err != nil {
+		err := s.server.Run(ctx, &mcp.StdioTransport{})
+		if err != nil && ctx.Err() == nil {
 			return err
 		}
 		return nil
@@ -62,5 +69,15 @@ func (s Server) Run() error {
 		return s.server
 	}, nil)
 
-	return http.ListenAndServe(s.addr, handler)
+	httpServer := &http.Server{Addr: s.addr, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		httpServer.Shutdown(context.Background())
+	}()
+
+	err := httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	godocrag "godoc-rag"
+
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
@@ -12,20 +14,42 @@ var searchTool = &mcp.Tool{
 	Description: `Perform semantic search over Go package documentation.
 This tool searches embeddings of Go package docs stored in pgvector to return the most
 relevant code references, explanations, or API details. Use this to understand unfamiliar
-packages, find functions, or resolve coding questions that may be answered by Go documentation.`,
+packages, find functions, or resolve coding questions that may be answered by Go documentation.
+Results are also ranked by keyword matches, so queries can include exact identifiers copied
+from code or compiler errors.`,
 }
 
 type Input struct {
 	Query string `json:"query" jsonschema:"Natural language query about Go code, libraries, or APIs."`
 	Limit int    `json:"limit" jsonschema:"Number of results to get from the search"`
+
+	KeywordWeight *float64 `json:"keyword_weight,omitempty" jsonschema:"Optional weight from 0 to 1 of keyword matches compared to semantic similarity. Use higher values when searching for exact identifiers"`
+	MinScore      *float64 `json:"min_score,omitempty" jsonschema:"Optional minimum score of results, where 0 returns all results"`
+	MMR           *bool    `json:"mmr,omitempty" jsonschema:"Optionally re-rank results for diversity so similar results, like several methods of the same type, do not crowd out the rest of an API"`
+	MMRLambda     *float64 `json:"mmr_lambda,omitempty" jsonschema:"Optional weight from 0 to 1 of relevance compared to diversity when mmr is used"`
+
+	PackagePrefix string   `json:"package_prefix,omitempty" jsonschema:"Optional import path to only search a package and its subpackages, like example.com/project/internal/billing"`
+	Types         []string `json:"types,omitempty" jsonschema:"Optional types of symbols to search, like function, struct, interface, type, const, var, enum, package, or example"`
+	Module        string   `json:"module,omitempty" jsonschema:"Optional module path to only search packages from the module"`
+	ModuleVersion string   `json:"module_version,omitempty" jsonschema:"Optional module version to only search, like v1.2.3"`
+	ExportedOnly  bool     `json:"exported_only,omitempty" jsonschema:"Only return symbols that are part of a package's exported API"`
 }
 
 type Data struct {
-	Type     string `jsonschema:"type of the symbol (function, struct, package, etc."`
-	Symbol   string `jsonschema:"name of the symbol"`
-	Data     string `jsonschema:"relevant context data (i.e. comment text)"`
-	Package  string `jsonschema:"name of the Go package"`
-	Filename string `jsonschema:"filename for the data"`
+	Type      string `jsonschema:"type of the symbol (function, struct, package, etc."`
+	Symbol    string `jsonschema:"name of the symbol"`
+	Signature string `jsonschema:"full declaration of functions and methods, including parameters and results"`
+	Data      string `jsonschema:"relevant context data (i.e. comment text)"`
+	Package   string `jsonschema:"name of the Go package"`
+	Filename  string `jsonschema:"filename for the data"`
+	Line      int    `jsonschema:"line in the file where a function is declared"`
+
+	Module        string `jsonschema:"path of the module containing the package"`
+	ModuleVersion string `jsonschema:"version of the module, which is empty for the main module"`
+
+	Score float64 `jsonschema:"relevance to the query, usually from 0 to 1 where higher is better. Low scores are likely unrelated to the query"`
+
+	Undocumented bool `jsonschema:"true if the symbol has no doc comment, so only the signature is available"`
 }
 
 type Output struct {
@@ -33,19 +57,51 @@ type Output struct {
 }
 
 func (s Server) semanticSearch(ctx context.Context, req *mcp.CallToolRequest, input Input) (*mcp.CallToolResult, Output, error) {
-	dataIter, getErr, err := s.loader.SemanticSearch(ctx, input.Query, input.Limit)
+	opts := s.defaults
+	opts.Limit = input.Limit
+	if input.KeywordWeight != nil {
+		opts.KeywordWeight = *input.KeywordWeight
+	}
+	if input.MinScore != nil {
+		opts.MinScore = *input.MinScore
+	}
+	if input.MMR != nil {
+		opts.MMR = *input.MMR
+	}
+	if input.MMRLambda != nil {
+		opts.MMRLambda = *input.MMRLambda
+	}
+	opts.Filter = godocrag.Filter{
+		PackagePrefix: input.PackagePrefix,
+		Types:         input.Types,
+		Module:        input.Module,
+		ModuleVersion: input.ModuleVersion,
+		ExportedOnly:  input.ExportedOnly,
+	}
+
+	dataIter, getErr, err := s.loader.SemanticSearch(ctx, input.Query, opts)
 	if err != nil {
 		return nil, Output{}, fmt.Errorf("error performing search: %w", err)
 	}
 
-	output := Output{}
+	// Data must be an empty array instead of null when there are no results
+	output := Output{Data: []Data{}}
 	for d := range dataIter {
 		output.Data = append(output.Data, Data{
-			Type:     d.Type,
-			Symbol:   d.Symbol,
-			Data:     d.Data,
-			Package:  d.Package,
-			Filename: d.Filename,
+			Type:      d.Type,
+			Symbol:    d.Symbol,
+			Signature: d.Signature,
+			Data:      d.Data,
+			Package:   d.Package,
+			Filename:  d.Filename,
+			Line:      d.Line,
+
+			Module:        d.Module,
+			ModuleVersion: d.ModuleVersion,
+
+			Score: d.Score,
+
+			Undocumented: d.Undocumented,
 		})
 	}
 	if err := getErr(); err != nil {
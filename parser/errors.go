@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// FileError describes a problem with a file or package that was reported while parsing. Files
+// that fail to parse are skipped and the rest of the packages are still indexed
+type FileError struct {
+	Package  string
+	Filename string
+	Err      error
+}
+
+func (e FileError) Error() string {
+	source := e.Filename
+	if source == "" {
+		source = e.Package
+	}
+	// Errors from go/scanner and go/packages already include the position
+	msg := e.Err.Error()
+	if strings.HasPrefix(msg, source) {
+		return msg
+	}
+	return fmt.Sprintf("%s: %s", source, msg)
+}
+
+func (e FileError) Unwrap() error {
+	return e.Err
+}
+
+// ParseErrors is returned by Parser.Error when one or more files or packages had errors
+type ParseErrors []FileError
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return "errors while parsing:\n" + strings.Join(msgs, "\n")
+}
+
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, fe := range e {
+		errs[i] = fe
+	}
+	return errs
+}
+
+// Packages returns the packages that had errors
+func (e ParseErrors) Packages() []string {
+	var result []string
+	for _, fe := range e {
+		if !slices.Contains(result, fe.Package) {
+			result = append(result, fe.Package)
+		}
+	}
+	return result
+}
+
+// addFileError adds an error for a file unless one was already reported by packages.Load
+func (e *ParseErrors) addFileError(packageName, filename string, err error) {
+	for _, fe := range *e {
+		if fe.Filename == filename {
+			return
+		}
+	}
+	*e = append(*e, FileError{Package: packageName, Filename: filename, Err: err})
+}
+
+// getPackageErrors converts errors reported by packages.Load. The same error can be reported by
+// a package and its test variants, and a file with a syntax error is reported by both go list and
+// the parser, so only the first error for each file is kept
+func getPackageErrors(pkgs []*packages.Package) ParseErrors {
+	var result ParseErrors
+	seen := map[string]bool{}
+	for _, pkg := range pkgs {
+		// Errors from test variants are reported for the package being tested
+		pkgPath := pkg.PkgPath
+		if pkg.ForTest != "" {
+			pkgPath = pkg.ForTest
+		}
+
+		// Prefer errors with a position, which are reported with the absolute path of the file
+		pkgErrs := slices.Clone(pkg.Errors)
+		slices.SortStableFunc(pkgErrs, func(a, b packages.Error) int {
+			switch {
+			case noPos(a) == noPos(b):
+				return 0
+			case noPos(a):
+				return 1
+			}
+			return -1
+		})
+
+		for _, pkgErr := range pkgErrs {
+			filename := errorFilename(pkgErr)
+
+			key := pkgErr.Error()
+			if filename != "" {
+				key = pkgPath + " " + filename
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			result = append(result, FileError{
+				Package:  pkgPath,
+				Filename: filename,
+				Err:      pkgErr,
+			})
+		}
+	}
+	return result
+}
+
+// errorFilename returns the absolute path of the file for an error from packages.Load, or an
+// empty string if the error is not for a file. Pos is formatted as "file:line:col". Errors from go
+// list don't have a Pos, but the message has the package followed by the position of each error,
+// relative to the working directory
+func errorFilename(pkgErr packages.Error) string {
+	pos := pkgErr.Pos
+	if noPos(pkgErr) {
+		header, rest, ok := strings.Cut(pkgErr.Msg, "\n")
+		if !ok || !strings.HasPrefix(header, "# ") {
+			return ""
+		}
+		pos = rest
+	}
+
+	filename, _, ok := strings.Cut(pos, ":")
+	if !ok || !strings.HasSuffix(filename, ".go") {
+		return ""
+	}
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+	return filename
+}
+
+// noPos returns true if the error is not associated with a position
+func noPos(pkgErr packages.Error) bool {
+	return pkgErr.Pos == "" || pkgErr.Pos == "-"
+}
@@ -1,12 +1,19 @@
 package parser
 
 import (
+	"context"
 	"fmt"
 	"go/ast"
+	"go/doc"
 	"go/parser"
+	"go/printer"
 	"go/token"
+	"go/types"
 	"log"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
 
 	godocrag "godoc-rag"
 
@@ -15,64 +22,318 @@ import (
 
 type Parser struct {
 	out  chan godocrag.Data
-	err  error
 	path string
+
+	errMu sync.Mutex
+	err   error
+
+	// typeCheck enables loading type information to resolve method sets, implemented
+	// interfaces, and underlying types
+	typeCheck bool
+	typeFacts map[string][]godocrag.Data
+
+	// module is the module of the package that is being parsed. It is added to all Data that is sent
+	module *packages.Module
 }
 
-func New(path string) *Parser {
+func New(path string, typeCheck bool) *Parser {
 	return &Parser{
-		out:  make(chan godocrag.Data),
-		err:  nil,
-		path: path,
+		out:       make(chan godocrag.Data),
+		err:       nil,
+		path:      path,
+		typeCheck: typeCheck,
 	}
 }
 
-func (p Parser) Error() error {
+// Error returns the error from parsing. It should be checked after the channel from Parse is closed
+func (p *Parser) Error() error {
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
 	return p.err
 }
 
-func (p *Parser) Parse() <-chan godocrag.Data {
+// Parse loads and parses the packages in the background and sends the Data on the returned
+// channel. The channel is always closed when parsing finishes, fails, or the context is cancelled
+func (p *Parser) Parse(ctx context.Context) <-chan godocrag.Data {
 	go func() {
-		pkgs, err := packages.Load(&packages.Config{
-			Mode: packages.NeedName | packages.NeedFiles,
-		}, p.path)
-		if err != nil {
-			p.err = err
-			return
-		}
-
-		fset := token.NewFileSet()
-		for _, pkg := range pkgs {
-			for _, fname := range pkg.GoFiles {
-				f, err := parser.ParseFile(fset, fname, nil, parser.ParseComments)
-				if err != nil {
-					panic(err)
-				}
-				p.parseAstFile(f, pkg.PkgPath, fname)
-			}
-		}
+		defer close(p.out)
 
-		close(p.out)
+		err := p.parse(ctx)
+
+		p.errMu.Lock()
+		p.err = err
+		p.errMu.Unlock()
 	}()
 	return p.out
 }
 
-func (p Parser) parseAstFile(node *ast.File, packageName, filename string) {
-	// Package doc
-	if node.Doc != nil {
-		p.out <- godocrag.Data{
-			Type:     "package",
-			Symbol:   packageName,
-			Data:     node.Doc.Text(),
-			Package:  packageName,
-			Filename: filename,
+func (p *Parser) parse(ctx context.Context) error {
+	mode := packages.NeedName | packages.NeedFiles | packages.NeedForTest | packages.NeedModule
+	if p.typeCheck {
+		// Only the matched packages are type-checked from source. Dependencies are loaded
+		// from export data
+		mode |= packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax | packages.NeedImports
+	}
+
+	pkgs, err := packages.Load(&packages.Config{
+		Context: ctx,
+		Mode:    mode,
+		Tests:   true,
+	}, p.path)
+	if err != nil {
+		return fmt.Errorf("error loading packages: %w", err)
+	}
+
+	if p.typeCheck {
+		p.typeFacts = getTypeFacts(pkgs)
+	}
+
+	errs := getPackageErrors(pkgs)
+
+	fset := token.NewFileSet()
+	for _, pkg := range groupPackageFiles(pkgs) {
+		p.module = pkg.module
+
+		var files []*ast.File
+		var filenames []string
+		for _, fname := range pkg.goFiles {
+			f, err := parser.ParseFile(fset, fname, nil, parser.ParseComments)
+			if err != nil {
+				errs.addFileError(pkg.path, fname, err)
+				continue
+			}
+			files = append(files, f)
+			filenames = append(filenames, fname)
 		}
+
+		enums := getEnums(fset, files, filenames)
+		for i, f := range files {
+			if !p.parseAstFile(ctx, fset, f, pkg.path, filenames[i], enums) {
+				return ctx.Err()
+			}
+		}
+		// Enums are sent separately if their type is not an exported type in the package
+		for _, name := range slices.Sorted(maps.Keys(enums)) {
+			data := enums[name]
+			data.Package = pkg.path
+			if !p.send(ctx, data) {
+				return ctx.Err()
+			}
+		}
+		if !p.parsePackageDoc(ctx, fset, files, pkg.path) {
+			return ctx.Err()
+		}
+
+		if len(pkg.testFiles) == 0 {
+			continue
+		}
+		for _, fname := range pkg.testFiles {
+			f, err := parser.ParseFile(fset, fname, nil, parser.ParseComments)
+			if err != nil {
+				errs.addFileError(pkg.path, fname, err)
+				continue
+			}
+			files = append(files, f)
+		}
+		if err := p.parseExamples(ctx, fset, files, pkg.path); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			errs = append(errs, FileError{Package: pkg.path, Err: err})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// send passes data to the consumer and returns false if the context is cancelled first
+func (p *Parser) send(ctx context.Context, data godocrag.Data) bool {
+	if p.module != nil {
+		data.Module = p.module.Path
+		data.ModuleVersion = p.module.Version
+	}
+
+	select {
+	case p.out <- data:
+		return true
+	case <-ctx.Done():
+		return false
 	}
+}
 
+// packageFiles holds the source and test files for a package after combining the test
+// variants returned by packages.Load
+type packageFiles struct {
+	path      string
+	module    *packages.Module
+	goFiles   []string
+	testFiles []string
+}
+
+func groupPackageFiles(pkgs []*packages.Package) []*packageFiles {
+	var result []*packageFiles
+	byPath := map[string]*packageFiles{}
+	get := func(path string) *packageFiles {
+		pf, ok := byPath[path]
+		if !ok {
+			pf = &packageFiles{path: path}
+			byPath[path] = pf
+			result = append(result, pf)
+		}
+		return pf
+	}
+
+	for _, pkg := range pkgs {
+		// Skip the generated test main packages
+		if strings.HasSuffix(pkg.ID, ".test") {
+			continue
+		}
+
+		if pkg.ForTest == "" {
+			pf := get(pkg.PkgPath)
+			pf.module = pkg.Module
+			pf.goFiles = append(pf.goFiles, pkg.GoFiles...)
+			continue
+		}
+
+		// Test variants also include the package's regular files, so only the test files are used
+		pf := get(pkg.ForTest)
+		for _, fname := range pkg.GoFiles {
+			if strings.HasSuffix(fname, "_test.go") && !slices.Contains(pf.testFiles, fname) {
+				pf.testFiles = append(pf.testFiles, fname)
+			}
+		}
+	}
+
+	return result
+}
+
+// parseExamples uses go/doc to find runnable Example functions in the package's test files and
+// associate them with the package, type, function, or method that they demonstrate
+func (p *Parser) parseExamples(ctx context.Context, fset *token.FileSet, files []*ast.File, packageName string) error {
+	pkgDoc, err := doc.NewFromFiles(fset, files, packageName, doc.PreserveAST)
+	if err != nil {
+		return fmt.Errorf("error reading examples: %w", err)
+	}
+
+	send := func(symbol string, examples []*doc.Example) bool {
+		for _, ex := range examples {
+			exampleSymbol := symbol
+			if ex.Suffix != "" {
+				exampleSymbol += "_" + ex.Suffix
+			}
+			ok := p.send(ctx, godocrag.Data{
+				Type:     "example",
+				Symbol:   exampleSymbol,
+				Data:     getExampleText(fset, ex),
+				Package:  packageName,
+				Filename: fset.Position(ex.Code.Pos()).Filename,
+				Line:     fset.Position(ex.Code.Pos()).Line,
+			})
+			if !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	if !send(packageName, pkgDoc.Examples) {
+		return ctx.Err()
+	}
+	for _, f := range pkgDoc.Funcs {
+		if !send(f.Name, f.Examples) {
+			return ctx.Err()
+		}
+	}
+	for _, t := range pkgDoc.Types {
+		if !send(t.Name, t.Examples) {
+			return ctx.Err()
+		}
+		for _, f := range t.Funcs {
+			if !send(f.Name, f.Examples) {
+				return ctx.Err()
+			}
+		}
+		for _, m := range t.Methods {
+			if !send(m.Recv+"."+m.Name, m.Examples) {
+				return ctx.Err()
+			}
+		}
+	}
+
+	return nil
+}
+
+// getExampleText combines the example's doc comment, code, and expected output
+func getExampleText(fset *token.FileSet, ex *doc.Example) string {
+	var sb strings.Builder
+	if ex.Doc != "" {
+		sb.WriteString(ex.Doc)
+		sb.WriteRune('\n')
+	}
+
+	err := printer.Fprint(&sb, fset, &printer.CommentedNode{Node: ex.Code, Comments: ex.Comments})
+	if err != nil {
+		log.Printf("error printing example %s: %v", ex.Name, err)
+	}
+
+	if ex.Output != "" && !strings.Contains(sb.String(), "Output:") {
+		sb.WriteString("\n// Output:\n")
+		sb.WriteString(ex.Output)
+	}
+
+	return sb.String()
+}
+
+// parsePackageDoc sends a single Data with the package comments from all of the files, like go/doc.
+// The Filename is the first file with a package comment
+func (p *Parser) parsePackageDoc(ctx context.Context, fset *token.FileSet, files []*ast.File, packageName string) bool {
+	data := godocrag.Data{
+		Type:    "package",
+		Symbol:  packageName,
+		Package: packageName,
+	}
+
+	var docs []string
+	for _, f := range files {
+		if f.Doc == nil {
+			continue
+		}
+		if data.Filename == "" {
+			data.Filename = fset.Position(f.Package).Filename
+		}
+		docs = append(docs, f.Doc.Text())
+	}
+	if len(docs) == 0 {
+		return true
+	}
+
+	data.Data = strings.Join(docs, "\n")
+	return p.send(ctx, data)
+}
+
+// parseAstFile sends Data for the file's exported declarations. Enums for the file's types are
+// added to the type and removed from enums. It returns false if the context was cancelled before
+// all of the Data was sent
+func (p *Parser) parseAstFile(ctx context.Context, fset *token.FileSet, node *ast.File, packageName, filename string, enums map[string]godocrag.Data) bool {
 	// Walk through declarations
 	for _, decl := range node.Decls {
 		switch d := decl.(type) {
 		case *ast.GenDecl:
+			if d.Tok == token.CONST || d.Tok == token.VAR {
+				for _, data := range getValueData(d) {
+					data.Package = packageName
+					data.Filename = filename
+					if !p.send(ctx, data) {
+						return false
+					}
+				}
+				continue
+			}
+
 			for _, spec := range d.Specs {
 				s, ok := spec.(*ast.TypeSpec)
 				if !ok || !s.Name.IsExported() {
@@ -81,11 +342,20 @@ func (p Parser) parseAstFile(node *ast.File, packageName, filename string) {
 				data := getTypeData(s, d)
 				data.Package = packageName
 				data.Filename = filename
-				p.out <- data
+				for _, fact := range p.typeFacts[packageName+"."+s.Name.Name] {
+					data.AddChild(fact)
+				}
+				if enum, ok := enums[s.Name.Name]; ok {
+					data.AddChild(enum)
+					delete(enums, s.Name.Name)
+				}
+				if !p.send(ctx, data) {
+					return false
+				}
 			}
 
 		case *ast.FuncDecl:
-			if d.Doc == nil || !d.Name.IsExported() {
+			if !d.Name.IsExported() {
 				continue
 			}
 			symbol := d.Name.Name
@@ -93,15 +363,41 @@ func (p Parser) parseAstFile(node *ast.File, packageName, filename string) {
 				recName := getTypeName(d.Recv.List[0].Type)
 				symbol = recName + "." + symbol
 			}
-			p.out <- godocrag.Data{
-				Type:     "function",
-				Symbol:   symbol,
-				Data:     d.Doc.Text(),
-				Package:  packageName,
-				Filename: filename,
+			data := godocrag.Data{
+				Type:         "function",
+				Symbol:       symbol,
+				Signature:    getFuncSignature(fset, d),
+				Package:      packageName,
+				Filename:     filename,
+				Line:         fset.Position(d.Pos()).Line,
+				Undocumented: d.Doc == nil,
+			}
+			if d.Doc != nil {
+				data.Data = d.Doc.Text()
+			}
+			if !p.send(ctx, data) {
+				return false
 			}
 		}
 	}
+
+	return true
+}
+
+// getFuncSignature renders the declaration of a function, including the receiver, type
+// parameters, parameters, and results, without its doc comment or body
+func getFuncSignature(fset *token.FileSet, d *ast.FuncDecl) string {
+	var sb strings.Builder
+	err := printer.Fprint(&sb, fset, &ast.FuncDecl{
+		Recv: d.Recv,
+		Name: d.Name,
+		Type: d.Type,
+	})
+	if err != nil {
+		log.Printf("error printing signature for %s: %v", d.Name.Name, err)
+		return ""
+	}
+	return sb.String()
 }
 
 func getTypeName(t ast.Expr) string {
@@ -112,6 +408,8 @@ func getTypeName(t ast.Expr) string {
 		return "*" + getTypeName(r.X)
 	case *ast.IndexExpr:
 		return getTypeName(r.X)
+	case *ast.IndexListExpr:
+		return getTypeName(r.X)
 	default:
 		log.Printf("unknown type for getTypeName: %T", r)
 	}
@@ -153,11 +451,14 @@ func getTypeData(s *ast.TypeSpec, g *ast.GenDecl) godocrag.Data {
 			}
 			fieldName := strings.Join(names, ", ")
 			if fieldName == "" { // embedded struct
-				fieldName = fmt.Sprintf("embedded %s", fmt.Sprint(field.Type))
+				fieldName = fmt.Sprintf("embedded %s", types.ExprString(field.Type))
 			}
 
-			// Print field type
-			fieldType := fmt.Sprint(field.Type)
+			// Print field type and tag, since tags describe how the type is serialized
+			fieldType := types.ExprString(field.Type)
+			if field.Tag != nil {
+				fieldType += " " + field.Tag.Value
+			}
 
 			var comment string
 			if field.Doc != nil {
@@ -181,8 +482,15 @@ func getTypeData(s *ast.TypeSpec, g *ast.GenDecl) godocrag.Data {
 			}
 			methodName := strings.Join(names, ", ")
 			if methodName == "" { // embedded interface
-				methodName = fmt.Sprintf("embedded %s", fmt.Sprint(method.Type))
+				methodName = fmt.Sprintf("embedded %s", types.ExprString(method.Type))
+			}
+
+			// Print method signature without the "func" keyword, like it is declared in the interface
+			var signature string
+			if ft, ok := method.Type.(*ast.FuncType); ok {
+				signature = methodName + strings.TrimPrefix(types.ExprString(ft), "func")
 			}
+
 			var comment string
 			if method.Doc != nil {
 				comment += method.Doc.Text()
@@ -192,12 +500,147 @@ func getTypeData(s *ast.TypeSpec, g *ast.GenDecl) godocrag.Data {
 			}
 
 			data.AddChild(godocrag.Data{
-				Type:   "method",
-				Symbol: fmt.Sprintf("%s.%s", data.Symbol, methodName),
-				Data:   comment,
+				Type:      "method",
+				Symbol:    fmt.Sprintf("%s.%s", data.Symbol, methodName),
+				Signature: signature,
+				Data:      comment,
 			})
 		}
 	}
 
 	return data
 }
+
+// getEnums combines the const groups using iota with a declared type in all of a package's
+// files, so each type has a single enum with each value as a child. Enums are keyed by their type
+func getEnums(fset *token.FileSet, files []*ast.File, filenames []string) map[string]godocrag.Data {
+	enums := map[string]godocrag.Data{}
+	for i, f := range files {
+		for _, decl := range f.Decls {
+			g, ok := decl.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			enumType := getEnumType(g)
+			if enumType == "" {
+				continue
+			}
+
+			var values []godocrag.Data
+			for _, spec := range g.Specs {
+				s := spec.(*ast.ValueSpec)
+				for _, n := range s.Names {
+					if !n.IsExported() {
+						continue
+					}
+					values = append(values, godocrag.Data{
+						Type:   "const",
+						Symbol: n.Name,
+						Data:   getSpecComment(s),
+					})
+				}
+			}
+			if len(values) == 0 {
+				continue
+			}
+
+			data, ok := enums[enumType]
+			if !ok {
+				data = godocrag.Data{
+					Type:     "enum",
+					Symbol:   enumType,
+					Filename: filenames[i],
+					Line:     fset.Position(g.Pos()).Line,
+				}
+			}
+			if g.Doc != nil {
+				data.Data = strings.TrimSpace(data.Data + "\n" + g.Doc.Text())
+			}
+			for _, v := range values {
+				data.AddChild(v)
+			}
+			enums[enumType] = data
+		}
+	}
+
+	return enums
+}
+
+// getValueData creates Data for the exported names in a const or var declaration. Enums are
+// skipped since they are combined by getEnums
+func getValueData(g *ast.GenDecl) []godocrag.Data {
+	if getEnumType(g) != "" {
+		return nil
+	}
+
+	kind := "var"
+	if g.Tok == token.CONST {
+		kind = "const"
+	}
+
+	var result []godocrag.Data
+	for _, spec := range g.Specs {
+		s, ok := spec.(*ast.ValueSpec)
+		if !ok {
+			continue
+		}
+
+		comment := getSpecComment(s)
+		// Use the declaration's comment for single declarations or when the value is undocumented
+		if comment == "" && g.Doc != nil {
+			comment = g.Doc.Text()
+		}
+
+		for _, n := range s.Names {
+			if !n.IsExported() {
+				continue
+			}
+			result = append(result, godocrag.Data{
+				Type:   kind,
+				Symbol: n.Name,
+				Data:   comment,
+			})
+		}
+	}
+
+	return result
+}
+
+// getEnumType returns the declared type of a const group that uses iota, or an empty string
+// if the declaration is not an enum
+func getEnumType(g *ast.GenDecl) string {
+	if g.Tok != token.CONST || !g.Lparen.IsValid() || len(g.Specs) == 0 {
+		return ""
+	}
+
+	first, ok := g.Specs[0].(*ast.ValueSpec)
+	if !ok || first.Type == nil {
+		return ""
+	}
+
+	usesIota := false
+	for _, v := range first.Values {
+		ast.Inspect(v, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Name == "iota" {
+				usesIota = true
+			}
+			return !usesIota
+		})
+	}
+	if !usesIota {
+		return ""
+	}
+
+	return types.ExprString(first.Type)
+}
+
+func getSpecComment(s *ast.ValueSpec) string {
+	var comment string
+	if s.Doc != nil {
+		comment += s.Doc.Text()
+	}
+	if s.Comment != nil {
+		comment += s.Comment.Text()
+	}
+	return comment
+}
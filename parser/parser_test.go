@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	godocrag "godoc-rag"
+)
+
+// parseAll parses the pattern and returns the Data keyed by type and symbol
+func parseAll(t *testing.T, pattern string) map[[2]string]godocrag.Data {
+	t.Helper()
+
+	p := New(pattern, false)
+	result := map[[2]string]godocrag.Data{}
+	for d := range p.Parse(context.Background()) {
+		result[[2]string{d.Type, d.Symbol}] = d
+	}
+	if err := p.Error(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return result
+}
+
+func TestParseExamples(t *testing.T) {
+	data := parseAll(t, "godoc-rag/example")
+
+	tests := []struct {
+		symbol string
+		output string
+	}{
+		{"NewPerson", "Alice 30"},
+		{"*Person.Greet", "Hello, my name is Bob!"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.symbol, func(t *testing.T) {
+			d, ok := data[[2]string{"example", tt.symbol}]
+			if !ok {
+				t.Fatalf("missing example for %s", tt.symbol)
+			}
+			if filepath.Base(d.Filename) != "example_test.go" {
+				t.Errorf("expected example_test.go but got %q", d.Filename)
+			}
+			if d.Line == 0 {
+				t.Error("expected Line to be set")
+			}
+			if !strings.Contains(d.Data, "Output:") || !strings.Contains(d.Data, tt.output) {
+				t.Errorf("expected output block with %q in:\n%s", tt.output, d.Data)
+			}
+		})
+	}
+}
+
+func TestParseUniqueKeys(t *testing.T) {
+	p := New("./testdata/multidoc", false)
+
+	counts := map[[2]string]int{}
+	var pkgDoc godocrag.Data
+	for d := range p.Parse(context.Background()) {
+		counts[[2]string{d.Type, d.Symbol}]++
+		if d.Type == "package" {
+			pkgDoc = d
+		}
+	}
+	if err := p.Error(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for key, n := range counts {
+		if n != 1 {
+			t.Errorf("expected one Data for %v but got %d", key, n)
+		}
+	}
+
+	for _, symbol := range []string{"Pair.Key", "Entry.Key", "*Pair.Set"} {
+		if counts[[2]string{"function", symbol}] != 1 {
+			t.Errorf("missing function %s", symbol)
+		}
+	}
+
+	if !strings.Contains(pkgDoc.Data, "more than one file") || !strings.Contains(pkgDoc.Data, "second package comment") {
+		t.Errorf("expected package docs from both files but got:\n%s", pkgDoc.Data)
+	}
+	if filepath.Base(pkgDoc.Filename) != "a.go" {
+		t.Errorf("expected package doc from a.go but got %q", pkgDoc.Filename)
+	}
+}
+
+func TestParseEnums(t *testing.T) {
+	p := New("./testdata/enums", false)
+
+	counts := map[[2]string]int{}
+	data := map[[2]string]godocrag.Data{}
+	for d := range p.Parse(context.Background()) {
+		key := [2]string{d.Type, d.Symbol}
+		counts[key]++
+		data[key] = d
+	}
+	if err := p.Error(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for key, n := range counts {
+		if n != 1 {
+			t.Errorf("expected one Data for %v but got %d", key, n)
+		}
+	}
+
+	if _, ok := data[[2]string{"enum", "Kind"}]; ok {
+		t.Error("expected Kind enum to be part of the type")
+	}
+	kind, ok := data[[2]string{"alias for int", "Kind"}]
+	if !ok {
+		t.Fatal("missing type Kind")
+	}
+	for _, value := range []string{"KindA", "KindB", "KindX", "KindY", "extended kind"} {
+		if !strings.Contains(kind.String(), value) {
+			t.Errorf("expected %q in Kind:\n%s", value, kind.String())
+		}
+	}
+
+	// level is not exported, so its values are combined in a separate enum
+	level, ok := data[[2]string{"enum", "level"}]
+	if !ok {
+		t.Fatal("missing enum level")
+	}
+	for _, value := range []string{"LevelLow", "LevelLower", "LevelHigh", "Low levels", "High levels"} {
+		if !strings.Contains(level.String(), value) {
+			t.Errorf("expected %q in level:\n%s", value, level.String())
+		}
+	}
+	if filepath.Base(level.Filename) != "kind.go" {
+		t.Errorf("expected kind.go but got %q", level.Filename)
+	}
+}
+
+func TestParseErrorsOncePerFile(t *testing.T) {
+	for _, typeCheck := range []bool{false, true} {
+		p := New("./testdata/syntaxerr", typeCheck)
+
+		var symbols []string
+		for d := range p.Parse(context.Background()) {
+			symbols = append(symbols, d.Symbol)
+		}
+		if !slices.Contains(symbols, "Good") {
+			t.Errorf("expected Good from the file without errors with typeCheck %v but got %v", typeCheck, symbols)
+		}
+
+		var parseErrs ParseErrors
+		if !errors.As(p.Error(), &parseErrs) {
+			t.Fatalf("expected ParseErrors with typeCheck %v but got %v", typeCheck, p.Error())
+		}
+		if len(parseErrs) != 1 {
+			t.Fatalf("expected one error with typeCheck %v but got %d:\n%v", typeCheck, len(parseErrs), parseErrs)
+		}
+		if !filepath.IsAbs(parseErrs[0].Filename) || filepath.Base(parseErrs[0].Filename) != "b.go" {
+			t.Errorf("expected absolute path to b.go but got %q", parseErrs[0].Filename)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+// Package enums has enums with more than one const group
+package enums
+
+// Kind is the kind of a value
+type Kind int
+
+// Basic kinds
+const (
+	KindA Kind = iota // KindA is the first kind
+	KindB
+)
+
+type level int
+
+// Low levels
+const (
+	LevelLow level = iota
+	LevelLower
+)
@@ -0,0 +1,12 @@
+package enums
+
+// Extended kinds
+const (
+	KindX Kind = iota + 100 // KindX is an extended kind
+	KindY
+)
+
+// High levels
+const (
+	LevelHigh level = iota + 10
+)
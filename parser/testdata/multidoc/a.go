@@ -0,0 +1,23 @@
+// Package multidoc has package comments in more than one file
+package multidoc
+
+// Pair holds a key and a value
+type Pair[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// Key returns the key
+func (p Pair[K, V]) Key() K {
+	return p.key
+}
+
+// Entry holds a name
+type Entry struct {
+	name string
+}
+
+// Key returns the name
+func (e Entry) Key() string {
+	return e.name
+}
@@ -0,0 +1,7 @@
+// The second package comment is combined with the first one
+package multidoc
+
+// Set updates the value
+func (p *Pair[K, V]) Set(value V) {
+	p.value = value
+}
@@ -0,0 +1,4 @@
+package syntaxerr
+
+// Good is fine
+func Good() {}
@@ -0,0 +1,4 @@
+package syntaxerr
+
+func Bad( {
+}
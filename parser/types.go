@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+
+	godocrag "godoc-rag"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// getTypeFacts uses the type-checked packages to resolve information that is not available from
+// the syntax alone. It returns child Data for each exported named type, keyed by package path and
+// type name, describing the underlying type, the full method set including promoted methods, and
+// the interfaces that the type implements from the indexed packages
+func getTypeFacts(pkgs []*packages.Package) map[string][]godocrag.Data {
+	var named, interfaces []*types.TypeName
+	for _, pkg := range pkgs {
+		// Test variants are type-checked separately, so their objects are not comparable with the
+		// regular packages
+		if pkg.ForTest != "" || pkg.Types == nil || strings.HasSuffix(pkg.ID, ".test") {
+			continue
+		}
+
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || !tn.Exported() {
+				continue
+			}
+			named = append(named, tn)
+
+			iface, ok := tn.Type().Underlying().(*types.Interface)
+			if ok && !iface.Empty() && !isGeneric(tn) {
+				interfaces = append(interfaces, tn)
+			}
+		}
+	}
+
+	facts := map[string][]godocrag.Data{}
+	for _, tn := range named {
+		key := tn.Pkg().Path() + "." + tn.Name()
+		facts[key] = append(getUnderlyingData(tn), getMethodSetData(tn)...)
+		facts[key] = append(facts[key], getImplementsData(tn, interfaces)...)
+	}
+
+	return facts
+}
+
+func isGeneric(tn *types.TypeName) bool {
+	n, ok := tn.Type().(*types.Named)
+	return ok && n.TypeParams().Len() > 0
+}
+
+// getUnderlyingData resolves aliases and the underlying type of named types. Structs and
+// interfaces are skipped since their fields and methods are already included
+func getUnderlyingData(tn *types.TypeName) []godocrag.Data {
+	qualifier := types.RelativeTo(tn.Pkg())
+
+	if tn.IsAlias() {
+		return []godocrag.Data{{
+			Type:   "alias",
+			Symbol: tn.Name(),
+			Data:   types.TypeString(types.Unalias(tn.Type()), qualifier),
+		}}
+	}
+
+	switch tn.Type().Underlying().(type) {
+	case *types.Struct, *types.Interface:
+		return nil
+	}
+
+	return []godocrag.Data{{
+		Type:   "underlying",
+		Symbol: tn.Name(),
+		Data:   types.TypeString(tn.Type().Underlying(), qualifier),
+	}}
+}
+
+// getMethodSetData lists the exported methods of the type and its pointer, including methods
+// promoted from embedded fields
+func getMethodSetData(tn *types.TypeName) []godocrag.Data {
+	if tn.IsAlias() || types.IsInterface(tn.Type()) {
+		return nil
+	}
+
+	qualifier := types.RelativeTo(tn.Pkg())
+	valueMethods := types.NewMethodSet(tn.Type())
+	pointerMethods := types.NewMethodSet(types.NewPointer(tn.Type()))
+
+	var result []godocrag.Data
+	for sel := range pointerMethods.Methods() {
+		fn, ok := sel.Obj().(*types.Func)
+		if !ok || !fn.Exported() {
+			continue
+		}
+
+		var notes []string
+		if len(sel.Index()) > 1 {
+			recv := fn.Signature().Recv().Type()
+			notes = append(notes, fmt.Sprintf("promoted from %s", types.TypeString(recv, qualifier)))
+		}
+		if valueMethods.Lookup(fn.Pkg(), fn.Name()) == nil {
+			notes = append(notes, fmt.Sprintf("only in the method set of *%s", tn.Name()))
+		}
+
+		result = append(result, godocrag.Data{
+			Type:      "method",
+			Symbol:    tn.Name() + "." + fn.Name(),
+			Signature: types.ObjectString(fn, qualifier),
+			Data:      strings.Join(notes, "; "),
+		})
+	}
+
+	return result
+}
+
+// getImplementsData finds the interfaces that are implemented by the type or its pointer
+func getImplementsData(tn *types.TypeName, interfaces []*types.TypeName) []godocrag.Data {
+	if isGeneric(tn) {
+		return nil
+	}
+
+	qualifier := types.RelativeTo(tn.Pkg())
+
+	var result []godocrag.Data
+	for _, iface := range interfaces {
+		if iface == tn {
+			continue
+		}
+
+		ifaceType := iface.Type().Underlying().(*types.Interface)
+		implementer := tn.Name()
+		switch {
+		case types.Implements(tn.Type(), ifaceType):
+		case !types.IsInterface(tn.Type()) && types.Implements(types.NewPointer(tn.Type()), ifaceType):
+			implementer = "*" + implementer
+		default:
+			continue
+		}
+
+		result = append(result, godocrag.Data{
+			Type:   "implements",
+			Symbol: implementer,
+			Data:   types.TypeString(iface.Type(), qualifier),
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,112 @@
+package rag
+
+import (
+	"cmp"
+	"context"
+	"iter"
+	"slices"
+
+	godocrag "godoc-rag"
+	"godoc-rag/store"
+)
+
+const (
+	// rrfK reduces the impact of the top few ranks so a result that is ranked well by both
+	// searches beats one that is ranked first by only one of them
+	rrfK = 60
+	// hybridCandidates is how many more results than the limit are fetched from each search
+	hybridCandidates = 4
+)
+
+// hybridSearch runs vector and keyword searches and combines the rankings with weighted
+// reciprocal rank fusion. Results with a Score below the MinScore are excluded before the limit
+// is applied
+func (l Loader) hybridSearch(ctx context.Context, query string, queryEmbedding []float32, opts godocrag.SearchOptions) (iter.Seq[godocrag.Data], func() error, error) {
+	candidates := max(opts.Limit*hybridCandidates, opts.Limit+20)
+
+	vectorResults, err := collect(l.vectorSearch(ctx, queryEmbedding, candidates, opts.Filter))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keywordResults, err := collect(l.store.KeywordSearch(ctx, query, candidates, opts.Filter))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := fuseRankings(
+		rankedList{vectorResults, 1 - opts.KeywordWeight},
+		rankedList{keywordResults, opts.KeywordWeight},
+	)
+	results = slices.DeleteFunc(results, func(d godocrag.Data) bool {
+		return d.Score < opts.MinScore
+	})
+	if len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+
+	return slices.Values(results), func() error { return nil }, nil
+}
+
+type rankedList struct {
+	results []godocrag.Data
+	weight  float64
+}
+
+// fuseRankings orders the results by the sum of weight / (rrfK + rank) across all lists. Since
+// the fused rank only depends on positions, a result ranked first by a single list would score
+// well even if it is a poor match. The Score of each result is the weighted sum of its Score in
+// each list instead, where lists that do not include the result add 0
+func fuseRankings(lists ...rankedList) []godocrag.Data {
+	type fused struct {
+		data  godocrag.Data
+		rrf   float64
+		score float64
+		// order is when the result was first seen so ties are deterministic
+		order int
+	}
+
+	byKey := map[store.Key]*fused{}
+	var results []*fused
+	for _, list := range lists {
+		for rank, d := range list.results {
+			key := dataKey(d)
+			f, ok := byKey[key]
+			if !ok {
+				f = &fused{data: d, order: len(results)}
+				byKey[key] = f
+				results = append(results, f)
+			}
+			f.rrf += list.weight / float64(rrfK+rank+1)
+			f.score += list.weight * d.Score
+		}
+	}
+
+	slices.SortFunc(results, func(a, b *fused) int {
+		return cmp.Or(cmp.Compare(b.rrf, a.rrf), cmp.Compare(a.order, b.order))
+	})
+
+	data := make([]godocrag.Data, len(results))
+	for i, f := range results {
+		data[i] = f.data
+		data[i].Score = f.score
+	}
+	return data
+}
+
+func dataKey(d godocrag.Data) store.Key {
+	return store.Key{Package: d.Package, Symbol: d.Symbol, Type: d.Type}
+}
+
+// collect reads all results from a search
+func collect(dataIter iter.Seq[godocrag.Data], getErr func() error, err error) ([]godocrag.Data, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	results := slices.Collect(dataIter)
+	if err := getErr(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
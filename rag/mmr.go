@@ -0,0 +1,95 @@
+package rag
+
+import (
+	"context"
+	"iter"
+	"math"
+	"slices"
+
+	godocrag "godoc-rag"
+	"godoc-rag/store"
+)
+
+// mmrCandidates is how many more results than the limit are considered when re-ranking with MMR
+const mmrCandidates = 4
+
+// mmrSearch fetches extra candidates and selects results with maximal marginal relevance, which
+// balances the relevance of each result with how similar it is to the results selected before it
+func (l Loader) mmrSearch(ctx context.Context, query string, queryEmbedding []float32, opts godocrag.SearchOptions) (iter.Seq[godocrag.Data], func() error, error) {
+	candidateOpts := opts
+	candidateOpts.Limit = max(opts.Limit*mmrCandidates, opts.Limit+20)
+
+	candidates, err := collect(l.search(ctx, query, queryEmbedding, candidateOpts))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	embeddings, err := l.candidateEmbeddings(ctx, candidates, len(queryEmbedding))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := maximalMarginalRelevance(candidates, embeddings, opts.MMRLambda, opts.Limit)
+	return slices.Values(results), func() error { return nil }, nil
+}
+
+// candidateEmbeddings returns the stored embedding with the dimension for each candidate.
+// Candidates without an embedding for the model, like results only found by keyword search, have
+// a nil embedding
+func (l Loader) candidateEmbeddings(ctx context.Context, candidates []godocrag.Data, dimension int) ([][]float32, error) {
+	keys := make([]store.Key, len(candidates))
+	for i, d := range candidates {
+		keys[i] = dataKey(d)
+	}
+
+	byKey, err := l.store.GetEmbeddings(ctx, l.embedder.Model(), dimension, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	embeddings := make([][]float32, len(candidates))
+	for i, key := range keys {
+		embeddings[i] = byKey[key]
+	}
+	return embeddings, nil
+}
+
+// maximalMarginalRelevance repeatedly selects the candidate with the highest
+// lambda * Score - (1 - lambda) * similarity to the most similar selected result. Candidates
+// without an embedding are not penalized for similarity
+func maximalMarginalRelevance(candidates []godocrag.Data, embeddings [][]float32, lambda float64, limit int) []godocrag.Data {
+	// maxSimilarity is the highest similarity of each candidate to a selected result
+	maxSimilarity := make([]float64, len(candidates))
+	selected := make([]bool, len(candidates))
+
+	var results []godocrag.Data
+	for len(results) < limit && len(results) < len(candidates) {
+		best := -1
+		bestValue := math.Inf(-1)
+		for i, d := range candidates {
+			if selected[i] {
+				continue
+			}
+			// Candidates are ordered by relevance, so ties keep the original order
+			value := lambda*d.Score - (1-lambda)*maxSimilarity[i]
+			if value > bestValue {
+				best, bestValue = i, value
+			}
+		}
+
+		selected[best] = true
+		results = append(results, candidates[best])
+
+		if embeddings[best] == nil {
+			continue
+		}
+		for i := range candidates {
+			if selected[i] || embeddings[i] == nil {
+				continue
+			}
+			maxSimilarity[i] = max(maxSimilarity[i], store.CosineSimilarity(embeddings[i], embeddings[best]))
+		}
+	}
+
+	return results
+}
@@ -2,7 +2,6 @@ package rag
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"iter"
 	"strings"
@@ -10,89 +9,110 @@ import (
 	"github.com/ollama/ollama/api"
 
 	godocrag "godoc-rag"
+	"godoc-rag/embedding"
+	"godoc-rag/store"
 )
 
 const defaultLimit = 3
 
 type Loader struct {
-	db             *sql.DB
-	ollamaClient   *api.Client
-	embeddingModel string
-	queryModel     string
+	store        store.VectorStore
+	embedder     embedding.Embedder
+	ollamaClient *api.Client
+	queryModel   string
+	searchParams store.SearchParams
 }
 
-func NewLoader(db *sql.DB, ollamaClient *api.Client, embeddingModel, queryModel string) Loader {
+// NewLoader creates a Loader that searches using embeddings from the embedder and the search
+// params. The Ollama client and query model are used to generate responses to prompts
+func NewLoader(vs store.VectorStore, embedder embedding.Embedder, ollamaClient *api.Client, queryModel string, searchParams store.SearchParams) Loader {
 	return Loader{
-		db:             db,
-		ollamaClient:   ollamaClient,
-		embeddingModel: embeddingModel,
-		queryModel:     queryModel,
+		store:        vs,
+		embedder:     embedder,
+		ollamaClient: ollamaClient,
+		queryModel:   queryModel,
+		searchParams: searchParams,
 	}
 }
 
-func (l Loader) SemanticSearch(ctx context.Context, query string, limit int) (iter.Seq[godocrag.Data], func() error, error) {
-	req := api.EmbedRequest{
-		Model: l.embeddingModel,
-		Input: query,
+// SemanticSearch finds the Data matching the Filter that is most relevant to the query. Vector
+// similarity and keyword rankings are combined using the KeywordWeight. Results with a Score below
+// the MinScore are excluded, then results are re-ranked for diversity if MMR is set
+func (l Loader) SemanticSearch(ctx context.Context, query string, opts godocrag.SearchOptions) (iter.Seq[godocrag.Data], func() error, error) {
+	// The query is embedded once for vector search and to find the stored embeddings with the
+	// same dimension for MMR
+	var queryEmbedding []float32
+	if opts.KeywordWeight < 1 || opts.MMR {
+		embeddings, err := l.embedder.Embed(ctx, []string{query})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to generate embedding for query: %v", err)
+		}
+		queryEmbedding = embeddings[0]
 	}
 
-	resp, err := l.ollamaClient.Embed(ctx, &req)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to generate embedding for query: %v", err)
+	if opts.MMR {
+		return l.mmrSearch(ctx, query, queryEmbedding, opts)
 	}
+	return l.search(ctx, query, queryEmbedding, opts)
+}
 
-	// Convert query vector to Postgres array literal
-	strVals := make([]string, len(resp.Embeddings[0]))
-	for i, v := range resp.Embeddings[0] {
-		strVals[i] = fmt.Sprintf("%f", v)
+// search runs a vector, keyword, or hybrid search depending on the KeywordWeight
+func (l Loader) search(ctx context.Context, query string, queryEmbedding []float32, opts godocrag.SearchOptions) (iter.Seq[godocrag.Data], func() error, error) {
+	var dataIter iter.Seq[godocrag.Data]
+	var getErr func() error
+	var err error
+	switch {
+	case opts.KeywordWeight <= 0:
+		dataIter, getErr, err = l.vectorSearch(ctx, queryEmbedding, opts.Limit, opts.Filter)
+	case opts.KeywordWeight >= 1:
+		dataIter, getErr, err = l.store.KeywordSearch(ctx, query, opts.Limit, opts.Filter)
+	default:
+		// Hybrid search excludes results below the MinScore before limiting the fused results
+		return l.hybridSearch(ctx, query, queryEmbedding, opts)
 	}
-	queryVector := fmt.Sprintf("[%s]", strings.Join(strVals, ","))
-
-	// Query database for similar chunks using cosine similarity
-	rows, err := l.db.QueryContext(ctx, `
-		SELECT c.data, c.package, c.filename, c.symbol, c.type
-		FROM comment_data c
-		JOIN embeddings e ON c.id = e.id
-		ORDER BY embedding <=> $1 LIMIT $2
-	`, queryVector, limit)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to query similar chunks: %v", err)
+	if err != nil || opts.MinScore == 0 {
+		return dataIter, getErr, err
 	}
 
-	var errorResult error
+	// Results from the store are ordered by Score, so skipping the results below the MinScore
+	// after the store limits them is the same as excluding them first
 	return func(yield func(godocrag.Data) bool) {
-			defer rows.Close()
-
-			for rows.Next() {
-				var d godocrag.Data
-				if err := rows.Scan(&d.Data, &d.Package, &d.Filename, &d.Symbol, &d.Type); err != nil {
-					errorResult = err
-					return
-				}
-
-				if !yield(d) {
-					return
-				}
+		for d := range dataIter {
+			if d.Score < opts.MinScore {
+				continue
 			}
-
-			if err := rows.Err(); err != nil {
-				errorResult = err
+			if !yield(d) {
 				return
 			}
-		}, func() error {
-			return errorResult
-		}, nil
+		}
+	}, getErr, nil
 }
 
-func (l Loader) Prompt(query string) error {
-	dataIter, getErr, err := l.SemanticSearch(context.Background(), query, defaultLimit)
+func (l Loader) vectorSearch(ctx context.Context, queryEmbedding []float32, limit int, filter godocrag.Filter) (iter.Seq[godocrag.Data], func() error, error) {
+	return l.store.Search(ctx, store.Query{
+		Model:        l.embedder.Model(),
+		Embedding:    queryEmbedding,
+		Limit:        limit,
+		Filter:       filter,
+		SearchParams: l.searchParams,
+	})
+}
+
+// Prompt searches for context related to the query and uses it to generate a response. The
+// default limit is used if opts.Limit is not set
+func (l Loader) Prompt(ctx context.Context, query string, opts godocrag.SearchOptions) error {
+	if opts.Limit <= 0 {
+		opts.Limit = defaultLimit
+	}
+
+	dataIter, getErr, err := l.SemanticSearch(ctx, query, opts)
 	if err != nil {
 		return err
 	}
 
 	var ragContext strings.Builder
 	for d := range dataIter {
-		ragContext.WriteString(fmt.Sprintf(`<context package=%q filename=%q symbol=%q type=%q>`, d.Package, d.Filename, d.Symbol, d.Type))
+		ragContext.WriteString(fmt.Sprintf(`<context package=%q filename=%q symbol=%q type=%q score="%.3f">`, d.Package, d.Filename, d.Symbol, d.Type, d.Score))
 		ragContext.WriteString(d.Data)
 		ragContext.WriteString("</context>\n")
 	}
@@ -103,7 +123,7 @@ func (l Loader) Prompt(query string) error {
 	prompt := fmt.Sprintf("<user>%s</user>\n%s", query, ragContext.String())
 	fmt.Println(prompt)
 
-	err = l.ollamaClient.Generate(context.Background(), &api.GenerateRequest{
+	err = l.ollamaClient.Generate(ctx, &api.GenerateRequest{
 		Model:  l.queryModel,
 		Prompt: prompt,
 		Stream: new(bool),
@@ -111,6 +131,7 @@ func (l Loader) Prompt(query string) error {
 		System: `You will receive user prompts/queries along with real context from RAG.
 The user prompt will be surrounded by <user></user>
 The context will be surrounded by <context source="..."></context>
+The score attribute is how relevant the context is to the prompt, where higher is better. Rely less on context with low scores.
 Provide the user details about the source of the context that you use.
 If the context doesn't contain relevant information, say "I don't have enough information to answer that question.`,
 	}, func(gr api.GenerateResponse) error {
@@ -0,0 +1,234 @@
+package rag
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+
+	godocrag "godoc-rag"
+	"godoc-rag/embedding"
+	"godoc-rag/store"
+)
+
+var testData = []godocrag.Data{
+	{Type: "function", Symbol: "AddInt", Package: "example.com/math", Data: "AddInt returns the sum of two integers"},
+	{Type: "function", Symbol: "AddInt64", Package: "example.com/math", Data: "AddInt64 returns the sum of two int64 integers"},
+	{Type: "function", Symbol: "AddFloat", Package: "example.com/math", Data: "AddFloat returns the sum of two floats"},
+	{Type: "function", Symbol: "Max", Package: "example.com/math", Data: "Max returns the larger of two integers"},
+	{Type: "interface", Symbol: "Reader", Package: "example.com/io", Data: "Reader reads bytes from a stream"},
+	{Type: "interface", Symbol: "Writer", Package: "example.com/io", Data: "Writer writes bytes to a stream"},
+	{Type: "function", Symbol: "Copy", Package: "example.com/io", Data: "Copy copies bytes from a Reader to a Writer"},
+	{Type: "struct", Symbol: "Builder", Package: "example.com/strings", Data: "Builder builds a string by writing bytes"},
+}
+
+// newTestLoader stores the testData with embeddings from the Hash embedder
+func newTestLoader(t *testing.T) Loader {
+	t.Helper()
+
+	vs := store.NewMemory()
+	hash := embedding.NewHash(256)
+	for _, d := range testData {
+		id, err := vs.UpsertChunk(context.Background(), d, d.Hash())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		embeddings, err := hash.Embed(context.Background(), []string{d.String()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		err = vs.UpsertEmbedding(context.Background(), id, hash.Model(), d.Hash(), embeddings[0])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	return NewLoader(vs, hash, nil, "", store.SearchParams{})
+}
+
+func search(t *testing.T, l Loader, query string, opts godocrag.SearchOptions) []godocrag.Data {
+	t.Helper()
+
+	results, err := collect(l.SemanticSearch(context.Background(), query, opts))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return results
+}
+
+func symbols(results []godocrag.Data) []string {
+	var result []string
+	for _, d := range results {
+		result = append(result, d.Symbol)
+	}
+	return result
+}
+
+func TestSemanticSearch(t *testing.T) {
+	l := newTestLoader(t)
+
+	tests := []struct {
+		name          string
+		query         string
+		keywordWeight float64
+		first         string
+	}{
+		{"Vector", "reads bytes from a stream", 0, "Reader"},
+		{"Keyword", "Writer", 1, "Writer"},
+		{"Hybrid", "sum of two floats", 0.5, "AddFloat"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := search(t, l, tt.query, godocrag.SearchOptions{Limit: 3, KeywordWeight: tt.keywordWeight})
+			if len(results) == 0 || len(results) > 3 {
+				t.Fatalf("expected 1 to 3 results but got %v", symbols(results))
+			}
+			if results[0].Symbol != tt.first {
+				t.Errorf("expected %s first but got %v", tt.first, symbols(results))
+			}
+		})
+	}
+}
+
+func TestFuseRankings(t *testing.T) {
+	a := godocrag.Data{Symbol: "A", Score: 0.2}
+	b := godocrag.Data{Symbol: "B", Score: 0.9}
+	c := godocrag.Data{Symbol: "C", Score: 0.6}
+
+	results := fuseRankings(
+		rankedList{[]godocrag.Data{a, b}, 0.5},
+		rankedList{[]godocrag.Data{a, c}, 0.5},
+	)
+
+	// A is ranked first by both lists even though its Score is lower
+	if !slices.Equal(symbols(results), []string{"A", "B", "C"}) {
+		t.Errorf("expected [A B C] but got %v", symbols(results))
+	}
+}
+
+func TestSemanticSearchFilter(t *testing.T) {
+	l := newTestLoader(t)
+
+	for _, keywordWeight := range []float64{0, 0.5, 1} {
+		results := search(t, l, "bytes integers", godocrag.SearchOptions{
+			Limit:         10,
+			KeywordWeight: keywordWeight,
+			Filter:        godocrag.Filter{PackagePrefix: "example.com/io", Types: []string{"interface"}},
+		})
+		if len(results) == 0 {
+			t.Errorf("expected results with keyword weight %v", keywordWeight)
+		}
+		for _, d := range results {
+			if d.Package != "example.com/io" || d.Type != "interface" {
+				t.Errorf("unexpected %s %s.%s with keyword weight %v", d.Type, d.Package, d.Symbol, keywordWeight)
+			}
+		}
+	}
+}
+
+func TestSemanticSearchMinScore(t *testing.T) {
+	l := newTestLoader(t)
+
+	for _, keywordWeight := range []float64{0, 0.5, 1} {
+		all := search(t, l, "sum of two integers", godocrag.SearchOptions{Limit: len(testData), KeywordWeight: keywordWeight})
+		if len(all) < 3 {
+			t.Fatalf("expected at least 3 results but got %v", symbols(all))
+		}
+
+		// Only keep the results with the two highest scores. Hybrid results are not ordered by
+		// Score, so they must be excluded before the limit is applied to find both of them
+		scores := make([]float64, len(all))
+		for i, d := range all {
+			scores[i] = d.Score
+		}
+		slices.Sort(scores)
+		minScore := scores[len(scores)-2]
+		var expected []string
+		for _, d := range all {
+			if d.Score >= minScore {
+				expected = append(expected, d.Symbol)
+			}
+		}
+		expected = expected[:min(len(expected), 2)]
+
+		results := search(t, l, "sum of two integers", godocrag.SearchOptions{Limit: 2, KeywordWeight: keywordWeight, MinScore: minScore})
+		if !slices.Equal(symbols(results), expected) {
+			t.Errorf("expected %v with keyword weight %v but got %v", expected, keywordWeight, symbols(results))
+		}
+	}
+}
+
+func TestSemanticSearchScore(t *testing.T) {
+	l := newTestLoader(t)
+
+	for _, keywordWeight := range []float64{0, 0.5, 1} {
+		results := search(t, l, "sum of two integers", godocrag.SearchOptions{Limit: len(testData), KeywordWeight: keywordWeight})
+		for i, d := range results {
+			if d.Score <= 0 || d.Score > 1 {
+				t.Errorf("expected score from 0 to 1 for %s with keyword weight %v but got %f", d.Symbol, keywordWeight, d.Score)
+			}
+			// Hybrid results are ordered by the fused rank instead of the Score
+			if keywordWeight != 0.5 && i > 0 && d.Score > results[i-1].Score {
+				t.Errorf("expected results in order of score with keyword weight %v but got %v", keywordWeight, symbols(results))
+			}
+		}
+	}
+
+	t.Run("WeightedHybridScore", func(t *testing.T) {
+		results := fuseRankings(
+			rankedList{[]godocrag.Data{{Symbol: "A", Score: 0.2}, {Symbol: "B", Score: 0.1}}, 0.75},
+			rankedList{[]godocrag.Data{{Symbol: "B", Score: 0.8}}, 0.25},
+		)
+		expected := map[string]float64{"A": 0.75 * 0.2, "B": 0.75*0.1 + 0.25*0.8}
+		for _, d := range results {
+			if math.Abs(d.Score-expected[d.Symbol]) > 1e-9 {
+				t.Errorf("expected score %f for %s but got %f", expected[d.Symbol], d.Symbol, d.Score)
+			}
+		}
+	})
+
+	// A query that does not match anything should not have a high score just because some result
+	// is ranked first
+	t.Run("PoorMatch", func(t *testing.T) {
+		results := search(t, l, "kubernetes pod autoscaler quantum", godocrag.SearchOptions{Limit: 3, KeywordWeight: 0.5})
+		for _, d := range results {
+			if d.Score > 0.3 {
+				t.Errorf("expected a low score for %s but got %f", d.Symbol, d.Score)
+			}
+		}
+	})
+}
+
+func TestSemanticSearchMMR(t *testing.T) {
+	l := newTestLoader(t)
+
+	opts := godocrag.SearchOptions{Limit: 3, KeywordWeight: 0, MMR: true}
+
+	t.Run("Relevance", func(t *testing.T) {
+		opts := opts
+		opts.MMRLambda = 1
+
+		expected := search(t, l, "AddInt sum of two integers", godocrag.SearchOptions{Limit: 3})
+		results := search(t, l, "AddInt sum of two integers", opts)
+		if !slices.Equal(symbols(results), symbols(expected)) {
+			t.Errorf("expected %v but got %v", symbols(expected), symbols(results))
+		}
+	})
+
+	t.Run("Diversity", func(t *testing.T) {
+		opts := opts
+		opts.MMRLambda = 0.3
+
+		results := search(t, l, "AddInt sum of two integers", opts)
+		if len(results) != 3 {
+			t.Fatalf("expected 3 results but got %v", symbols(results))
+		}
+		if results[0].Symbol != "AddInt" {
+			t.Errorf("expected AddInt first but got %v", symbols(results))
+		}
+		if results[1].Symbol == "AddInt64" {
+			t.Errorf("expected a result that is not similar to AddInt second but got %v", symbols(results))
+		}
+	})
+}
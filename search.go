@@ -0,0 +1,58 @@
+package godocrag
+
+import (
+	"slices"
+	"strings"
+)
+
+// SearchOptions configure a search of the embedded Data
+type SearchOptions struct {
+	Limit int
+	// KeywordWeight is the weight of keyword ranking compared to vector similarity when results
+	// are combined. 0 only uses vector similarity and 1 only uses keywords
+	KeywordWeight float64
+	// MinScore excludes results with a lower Score. It is not used if it is 0
+	MinScore float64
+	// MMR re-ranks results with maximal marginal relevance so results are more diverse
+	MMR bool
+	// MMRLambda is the weight of relevance compared to diversity when MMR is used. 1 only uses
+	// relevance and 0 only uses diversity
+	MMRLambda float64
+	Filter    Filter
+}
+
+// Filter limits a search to Data that matches all of the fields that are set
+type Filter struct {
+	// PackagePrefix matches the package with the import path and packages in its subdirectories
+	PackagePrefix string
+	// Types matches any of the types like function, struct, or interface
+	Types         []string
+	Module        string
+	ModuleVersion string
+	ExportedOnly  bool
+}
+
+// Match returns true if the Data matches the Filter
+func (f Filter) Match(d Data) bool {
+	if f.PackagePrefix != "" && !matchPackagePrefix(d.Package, f.PackagePrefix) {
+		return false
+	}
+	if len(f.Types) > 0 && !slices.Contains(f.Types, d.Type) {
+		return false
+	}
+	if f.Module != "" && d.Module != f.Module {
+		return false
+	}
+	if f.ModuleVersion != "" && d.ModuleVersion != f.ModuleVersion {
+		return false
+	}
+	if f.ExportedOnly && !d.Exported() {
+		return false
+	}
+	return true
+}
+
+func matchPackagePrefix(pkg, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return pkg == prefix || strings.HasPrefix(pkg, prefix+"/")
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// BenchmarkResult compares approximate search with exact search
+type BenchmarkResult struct {
+	Queries int
+	// Recall is the average fraction of exact results that were also found by approximate search
+	Recall float64
+
+	Approximate Latency
+	Exact       Latency
+}
+
+// Latency summarizes the time taken by searches
+type Latency struct {
+	Mean time.Duration
+	P50  time.Duration
+	P95  time.Duration
+}
+
+// Benchmark runs each query with its SearchParams and again with exact search, then compares the
+// recall and latency. Results are identified by their Key
+func Benchmark(ctx context.Context, vs VectorStore, queries []Query) (BenchmarkResult, error) {
+	result := BenchmarkResult{Queries: len(queries)}
+	if len(queries) == 0 {
+		return result, nil
+	}
+
+	var approxTimes, exactTimes []time.Duration
+	var totalRecall float64
+	for _, q := range queries {
+		approx, approxTime, err := timeSearch(ctx, vs, q)
+		if err != nil {
+			return BenchmarkResult{}, fmt.Errorf("error running approximate search: %w", err)
+		}
+
+		exactQuery := q
+		exactQuery.Exact = true
+		exact, exactTime, err := timeSearch(ctx, vs, exactQuery)
+		if err != nil {
+			return BenchmarkResult{}, fmt.Errorf("error running exact search: %w", err)
+		}
+
+		approxTimes = append(approxTimes, approxTime)
+		exactTimes = append(exactTimes, exactTime)
+
+		if len(exact) == 0 {
+			totalRecall++
+			continue
+		}
+		found := 0
+		for _, key := range exact {
+			if slices.Contains(approx, key) {
+				found++
+			}
+		}
+		totalRecall += float64(found) / float64(len(exact))
+	}
+
+	result.Recall = totalRecall / float64(len(queries))
+	result.Approximate = summarizeLatency(approxTimes)
+	result.Exact = summarizeLatency(exactTimes)
+
+	return result, nil
+}
+
+// timeSearch runs the search and returns the keys of the results and the time it took to read them
+func timeSearch(ctx context.Context, vs VectorStore, q Query) ([]Key, time.Duration, error) {
+	start := time.Now()
+
+	dataIter, getErr, err := vs.Search(ctx, q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var keys []Key
+	for d := range dataIter {
+		keys = append(keys, keyFor(d))
+	}
+	if err := getErr(); err != nil {
+		return nil, 0, err
+	}
+
+	return keys, time.Since(start), nil
+}
+
+func summarizeLatency(times []time.Duration) Latency {
+	slices.Sort(times)
+
+	var total time.Duration
+	for _, t := range times {
+		total += t
+	}
+
+	return Latency{
+		Mean: total / time.Duration(len(times)),
+		P50:  times[len(times)*50/100],
+		P95:  times[min(len(times)*95/100, len(times)-1)],
+	}
+}
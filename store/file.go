@@ -0,0 +1,98 @@
+package store
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// File implements VectorStore with a single local file so no database service is required. Data
+// is kept in Memory and written to the file when the store is closed
+type File struct {
+	*Memory
+	path string
+}
+
+var _ VectorStore = File{}
+
+// fileData is the gob-encoded contents of the file
+type fileData struct {
+	NextID int
+	Chunks []*memoryChunk
+}
+
+// OpenFile loads the store from the path. The file is created when the store is closed if it does
+// not already exist
+func OpenFile(path string) (File, error) {
+	f := File{
+		Memory: NewMemory(),
+		path:   path,
+	}
+
+	in, err := os.Open(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return f, nil
+	case err != nil:
+		return File{}, fmt.Errorf("failed to open file store: %w", err)
+	}
+	defer in.Close()
+
+	var data fileData
+	if err := gob.NewDecoder(in).Decode(&data); err != nil {
+		return File{}, fmt.Errorf("failed to read file store %q: %w", path, err)
+	}
+
+	f.nextID = data.NextID
+	for _, c := range data.Chunks {
+		f.chunks[c.ID] = c
+		f.keys[keyFor(c.Data)] = c.ID
+		if c.Vectors == nil {
+			c.Vectors = map[memoryModel]memoryEmbedding{}
+		}
+		for model := range c.Vectors {
+			f.models[model] = true
+		}
+	}
+
+	return f, nil
+}
+
+// Close writes the data to the file if it was modified. The data is written to a temporary file
+// first so an existing file is not corrupted if writing fails
+func (f File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.modified {
+		return nil
+	}
+
+	data := fileData{NextID: f.nextID}
+	for _, c := range f.chunks {
+		data.Chunks = append(data.Chunks, c)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create file store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("failed to write file store: %w", err)
+	}
+
+	f.modified = false
+	return nil
+}
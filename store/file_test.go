@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ids := storeTestData(t, f)
+	if err := f.Delete(context.Background(), ids[3]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	f, err = OpenFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	t.Run("Chunks", func(t *testing.T) {
+		chunks, err := f.List(context.Background(), "example.com/math")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(chunks) != 2 {
+			t.Fatalf("expected 2 chunks but got %d", len(chunks))
+		}
+		for i, c := range chunks {
+			if c.ID != ids[i] || c.Data.Data != testData[i].data.String() || c.Hash != testData[i].data.Hash() {
+				t.Errorf("unexpected chunk %d: %+v", i, c)
+			}
+		}
+
+		_, ok, err := f.GetChunk(context.Background(), keyFor(testData[3].data), testModel, 3)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ok {
+			t.Error("expected deleted chunk to be missing")
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		symbols := searchSymbols(t, f, Query{Model: testModel, Embedding: []float32{1, 0, 0}, Limit: 10})
+		if !slices.Equal(symbols, []string{"Add", "sum", "Sub"}) {
+			t.Errorf("expected [Add sum Sub] but got %v", symbols)
+		}
+	})
+
+	t.Run("NewIDs", func(t *testing.T) {
+		id, err := f.UpsertChunk(context.Background(), testData[3].data, testData[3].data.Hash())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if slices.Contains(ids, id) {
+			t.Errorf("expected a new ID but got %d", id)
+		}
+	})
+}
+
+func TestOpenFileMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The file is only written if it was modified
+	f, err = OpenFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	chunks, err := f.List(context.Background(), "example.com/math")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(chunks) != 0 {
+		t.Errorf("expected no chunks but got %d", len(chunks))
+	}
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// IndexMethod is the type of approximate nearest neighbor index
+type IndexMethod string
+
+const (
+	// IndexHNSW builds a graph that has better recall and latency than IVFFlat, but is slower to
+	// build and uses more memory
+	IndexHNSW IndexMethod = "hnsw"
+	// IndexIVFFlat divides embeddings into lists. It is faster to build, but should be created
+	// after the embeddings are stored since the lists are chosen from the existing data
+	IndexIVFFlat IndexMethod = "ivfflat"
+)
+
+// IndexOptions configure an approximate nearest neighbor index. Zero values use the defaults
+type IndexOptions struct {
+	Method IndexMethod
+	// M is the maximum number of connections for each node in an HNSW index
+	M int
+	// EfConstruction is the size of the candidate list used to build an HNSW index
+	EfConstruction int
+	// Lists is the number of lists in an IVFFlat index
+	Lists int
+}
+
+// Indexer is implemented by stores that support approximate nearest neighbor indexes. The
+// embeddings for each model and dimension have a separate index
+type Indexer interface {
+	// CreateIndex creates the index for the model's embeddings with the dimension, replacing an
+	// existing index
+	CreateIndex(ctx context.Context, model string, dimension int, opts IndexOptions) error
+	// DropIndex removes the index for the model's embeddings with the dimension so searches are exact
+	DropIndex(ctx context.Context, model string, dimension int) error
+}
+
+var _ Indexer = Postgres{}
+
+func (p Postgres) CreateIndex(ctx context.Context, model string, dimension int, opts IndexOptions) error {
+	table, ok, err := p.getModelTable(ctx, model, dimension)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("no embeddings stored for model %q with dimension %d", model, dimension)
+	}
+
+	var params []string
+	switch opts.Method {
+	case IndexHNSW:
+		if opts.M > 0 {
+			params = append(params, fmt.Sprintf("m = %d", opts.M))
+		}
+		if opts.EfConstruction > 0 {
+			params = append(params, fmt.Sprintf("ef_construction = %d", opts.EfConstruction))
+		}
+	case IndexIVFFlat:
+		if opts.Lists > 0 {
+			params = append(params, fmt.Sprintf("lists = %d", opts.Lists))
+		}
+	default:
+		return fmt.Errorf("unknown index method: %q", opts.Method)
+	}
+
+	query := fmt.Sprintf(
+		"CREATE INDEX %s ON %s USING %s (embedding vector_cosine_ops)",
+		indexName(table.name), table.name, opts.Method,
+	)
+	if len(params) > 0 {
+		query += fmt.Sprintf(" WITH (%s)", strings.Join(params, ", "))
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("DROP INDEX IF EXISTS %s", indexName(table.name))); err != nil {
+		return fmt.Errorf("failed to drop existing index: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (p Postgres) DropIndex(ctx context.Context, model string, dimension int) error {
+	table, ok, err := p.getModelTable(ctx, model, dimension)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("no embeddings stored for model %q with dimension %d", model, dimension)
+	}
+
+	_, err = p.db.ExecContext(ctx, fmt.Sprintf("DROP INDEX IF EXISTS %s", indexName(table.name)))
+	if err != nil {
+		return fmt.Errorf("failed to drop index: %w", err)
+	}
+
+	return nil
+}
+
+// indexName returns the name of the index for an embedding table. The "embeddings" prefix is
+// removed to keep the name within the identifier length limit
+func indexName(table string) string {
+	return strings.TrimPrefix(table, "embeddings_") + "_ann_idx"
+}
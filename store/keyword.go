@@ -0,0 +1,188 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"iter"
+	"math"
+	"slices"
+	"strings"
+
+	godocrag "godoc-rag"
+)
+
+// Weights for keyword matches in each part of a chunk. Matching the symbol is the strongest signal
+// since queries often contain exact identifiers
+const (
+	symbolKeywordWeight  = 1.0
+	packageKeywordWeight = 0.4
+	dataKeywordWeight    = 0.2
+)
+
+// keywordTerms returns the unique words in the text
+func keywordTerms(text string) []string {
+	terms := godocrag.Tokenize(text)
+	slices.Sort(terms)
+	return slices.Compact(terms)
+}
+
+// KeywordSearch ranks chunks by the inverse document frequency of matching words, weighted by the
+// part of the chunk that matched
+func (m *Memory) KeywordSearch(_ context.Context, text string, limit int, filter godocrag.Filter) (iter.Seq[godocrag.Data], func() error, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	terms := keywordTerms(text)
+
+	type chunkTerms struct {
+		chunk             *memoryChunk
+		symbolSet, pkgSet map[string]bool
+		dataCounts        map[string]int
+	}
+
+	docFreq := map[string]int{}
+	var chunks []chunkTerms
+	for _, c := range m.chunks {
+		if !filter.Match(c.Data) {
+			continue
+		}
+
+		ct := chunkTerms{
+			chunk:      c,
+			symbolSet:  map[string]bool{},
+			pkgSet:     map[string]bool{},
+			dataCounts: map[string]int{},
+		}
+		for _, t := range godocrag.Tokenize(c.Symbol) {
+			ct.symbolSet[t] = true
+		}
+		for _, t := range godocrag.Tokenize(c.Package) {
+			ct.pkgSet[t] = true
+		}
+		for _, t := range godocrag.Tokenize(c.Data.Data) {
+			ct.dataCounts[t]++
+		}
+
+		for _, t := range terms {
+			if ct.symbolSet[t] || ct.pkgSet[t] || ct.dataCounts[t] > 0 {
+				docFreq[t]++
+			}
+		}
+		chunks = append(chunks, ct)
+	}
+
+	type result struct {
+		id    int
+		data  godocrag.Data
+		score float64
+	}
+
+	var results []result
+	for _, ct := range chunks {
+		var score float64
+		for _, t := range terms {
+			if docFreq[t] == 0 {
+				continue
+			}
+			idf := math.Log(1 + (float64(len(chunks))-float64(docFreq[t])+0.5)/(float64(docFreq[t])+0.5))
+
+			var weight float64
+			if ct.symbolSet[t] {
+				weight += symbolKeywordWeight
+			}
+			if ct.pkgSet[t] {
+				weight += packageKeywordWeight
+			}
+			if n := ct.dataCounts[t]; n > 0 {
+				// Repeated words count less than the first match
+				weight += dataKeywordWeight * float64(n) / float64(n+1)
+			}
+			score += idf * weight
+		}
+
+		if score > 0 {
+			results = append(results, result{ct.chunk.ID, ct.chunk.Data, score})
+		}
+	}
+
+	slices.SortFunc(results, func(a, b result) int {
+		return cmp.Or(cmp.Compare(b.score, a.score), cmp.Compare(a.id, b.id))
+	})
+	if limit >= 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return func(yield func(godocrag.Data) bool) {
+			for _, r := range results {
+				// Normalize the score to be from 0 to 1, like Postgres ts_rank_cd normalization 32
+				r.data.Score = r.score / (r.score + 1)
+				if !yield(r.data) {
+					return
+				}
+			}
+		}, func() error {
+			return nil
+		}, nil
+}
+
+// KeywordSearch uses Postgres full-text search on the search_vector column, which has the words
+// from the symbol, package, and doc text with decreasing weights
+func (p Postgres) KeywordSearch(ctx context.Context, text string, limit int, filter godocrag.Filter) (iter.Seq[godocrag.Data], func() error, error) {
+	terms := keywordTerms(text)
+	if len(terms) == 0 {
+		return func(func(godocrag.Data) bool) {}, func() error { return nil }, nil
+	}
+
+	// Match any of the words so natural language queries still find results
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = "'" + t + "'"
+	}
+
+	args := []any{strings.Join(quoted, " | "), limit}
+	where, args := filterClause(filter, args)
+
+	rows, err := p.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %s, ts_rank_cd(c.search_vector, query, 32) AS score
+		FROM comment_data c, to_tsquery('simple', $1) query
+		WHERE c.search_vector @@ query AND %s
+		ORDER BY score DESC, c.id
+		LIMIT $2
+	`, dataColumns, where), args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query matching chunks: %v", err)
+	}
+
+	var errorResult error
+	return func(yield func(godocrag.Data) bool) {
+			defer rows.Close()
+
+			for rows.Next() {
+				d, err := scanData(rows)
+				if err != nil {
+					errorResult = err
+					return
+				}
+
+				if !yield(d) {
+					return
+				}
+			}
+
+			if err := rows.Err(); err != nil {
+				errorResult = err
+				return
+			}
+		}, func() error {
+			return errorResult
+		}, nil
+}
+
+// searchVectorText returns the words for the symbol, package, and doc text of the Data, which are
+// stored in the search_vector column with weights A, B, and C
+func searchVectorText(data godocrag.Data) (string, string, string) {
+	return strings.Join(godocrag.Tokenize(data.Symbol), " "),
+		strings.Join(godocrag.Tokenize(data.Package), " "),
+		strings.Join(godocrag.Tokenize(data.String()), " ")
+}
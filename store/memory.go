@@ -0,0 +1,234 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"iter"
+	"math"
+	"slices"
+	"sync"
+
+	godocrag "godoc-rag"
+)
+
+// Memory implements VectorStore in memory using exact cosine similarity search. It is useful for
+// tests and short-lived sessions that index a project at startup
+type Memory struct {
+	mu       sync.RWMutex
+	nextID   int
+	chunks   map[int]*memoryChunk
+	keys     map[Key]int
+	models   map[memoryModel]bool
+	modified bool
+}
+
+var _ VectorStore = &Memory{}
+
+type memoryChunk struct {
+	Chunk
+	// Vectors are keyed by model and dimension
+	Vectors map[memoryModel]memoryEmbedding
+}
+
+// memoryModel identifies the embeddings from a model with a dimension
+type memoryModel struct {
+	Model     string
+	Dimension int
+}
+
+type memoryEmbedding struct {
+	Hash   string
+	Vector []float32
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		nextID: 1,
+		chunks: map[int]*memoryChunk{},
+		keys:   map[Key]int{},
+		models: map[memoryModel]bool{},
+	}
+}
+
+func keyFor(data godocrag.Data) Key {
+	return Key{Package: data.Package, Symbol: data.Symbol, Type: data.Type}
+}
+
+func (m *Memory) UpsertChunk(_ context.Context, data godocrag.Data, hash string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := data
+	stored.Data = data.String()
+
+	key := keyFor(data)
+	id, ok := m.keys[key]
+	if !ok {
+		id = m.nextID
+		m.nextID++
+		m.keys[key] = id
+		m.chunks[id] = &memoryChunk{Vectors: map[memoryModel]memoryEmbedding{}}
+	}
+
+	c := m.chunks[id]
+	c.Chunk = Chunk{Data: stored, ID: id, Hash: hash}
+	m.modified = true
+
+	return id, nil
+}
+
+func (m *Memory) UpsertEmbedding(_ context.Context, id int, model, hash string, embedding []float32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.chunks[id]
+	if !ok {
+		return fmt.Errorf("chunk %d does not exist", id)
+	}
+
+	key := memoryModel{model, len(embedding)}
+	m.models[key] = true
+	c.Vectors[key] = memoryEmbedding{hash, embedding}
+	m.modified = true
+
+	return nil
+}
+
+func (m *Memory) GetChunk(_ context.Context, key Key, model string, dimension int) (Chunk, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.keys[key]
+	if !ok {
+		return Chunk{}, false, nil
+	}
+
+	c := m.chunks[id]
+	result := c.Chunk
+	result.EmbeddingHash = c.Vectors[memoryModel{model, dimension}].Hash
+	return result, true, nil
+}
+
+func (m *Memory) GetEmbeddings(_ context.Context, model string, dimension int, keys []Key) (map[Key][]float32, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := map[Key][]float32{}
+	for _, key := range keys {
+		id, ok := m.keys[key]
+		if !ok {
+			continue
+		}
+		if e, ok := m.chunks[id].Vectors[memoryModel{model, dimension}]; ok {
+			result[key] = e.Vector
+		}
+	}
+
+	return result, nil
+}
+
+func (m *Memory) List(_ context.Context, pkg string) ([]Chunk, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []Chunk
+	for _, c := range m.chunks {
+		if c.Package == pkg {
+			result = append(result, c.Chunk)
+		}
+	}
+	slices.SortFunc(result, func(a, b Chunk) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return result, nil
+}
+
+func (m *Memory) Delete(_ context.Context, ids ...int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range ids {
+		c, ok := m.chunks[id]
+		if !ok {
+			continue
+		}
+		delete(m.keys, keyFor(c.Data))
+		delete(m.chunks, id)
+		m.modified = true
+	}
+
+	return nil
+}
+
+// Search always uses exact search, so the SearchParams are ignored
+func (m *Memory) Search(_ context.Context, q Query) (iter.Seq[godocrag.Data], func() error, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	model := memoryModel{q.Model, len(q.Embedding)}
+	if !m.models[model] {
+		return nil, nil, fmt.Errorf("no embeddings stored for model %q with dimension %d", q.Model, len(q.Embedding))
+	}
+
+	type result struct {
+		id         int
+		data       godocrag.Data
+		similarity float64
+	}
+
+	var results []result
+	for _, c := range m.chunks {
+		e, ok := c.Vectors[model]
+		if !ok {
+			continue
+		}
+		if !q.Filter.Match(c.Data) {
+			continue
+		}
+		results = append(results, result{c.ID, c.Data, CosineSimilarity(e.Vector, q.Embedding)})
+	}
+
+	// Sort by ID for equal similarities so results are deterministic
+	slices.SortFunc(results, func(a, b result) int {
+		return cmp.Or(cmp.Compare(b.similarity, a.similarity), cmp.Compare(a.id, b.id))
+	})
+	if q.Limit >= 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+
+	return func(yield func(godocrag.Data) bool) {
+			for _, r := range results {
+				r.data.Score = r.similarity
+				if !yield(r.data) {
+					return
+				}
+			}
+		}, func() error {
+			return nil
+		}, nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// CosineSimilarity returns the cosine similarity of the embeddings, or 0 if they have different
+// dimensions or either one is all zeros
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
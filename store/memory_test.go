@@ -0,0 +1,285 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	godocrag "godoc-rag"
+)
+
+const testModel = "test"
+
+var testData = []struct {
+	data      godocrag.Data
+	embedding []float32
+}{
+	{godocrag.Data{Type: "function", Symbol: "Add", Package: "example.com/math", Data: "Add returns the sum of a and b"}, []float32{1, 0, 0}},
+	{godocrag.Data{Type: "function", Symbol: "Sub", Package: "example.com/math", Data: "Sub returns the difference of a and b"}, []float32{0.8, 0.6, 0}},
+	{godocrag.Data{Type: "function", Symbol: "sum", Package: "example.com/math/internal", Data: "sum adds all values"}, []float32{0.9, 0, 0.1}},
+	{godocrag.Data{Type: "struct", Symbol: "Reader", Package: "example.com/io", Data: "Reader reads bytes"}, []float32{0, 0, 1}},
+}
+
+// storeTestData upserts the testData with embeddings from testModel and returns the IDs
+func storeTestData(t *testing.T, vs VectorStore) []int {
+	t.Helper()
+
+	var ids []int
+	for _, d := range testData {
+		id, err := vs.UpsertChunk(context.Background(), d.data, d.data.Hash())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		err = vs.UpsertEmbedding(context.Background(), id, testModel, d.data.Hash(), d.embedding)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// searchSymbols returns the symbols of the search results in order
+func searchSymbols(t *testing.T, vs VectorStore, q Query) []string {
+	t.Helper()
+
+	dataIter, getErr, err := vs.Search(context.Background(), q)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var symbols []string
+	for d := range dataIter {
+		symbols = append(symbols, d.Symbol)
+	}
+	if err := getErr(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return symbols
+}
+
+func TestMemoryUpsert(t *testing.T) {
+	vs := NewMemory()
+	ids := storeTestData(t, vs)
+
+	t.Run("UpdateKeepsID", func(t *testing.T) {
+		updated := testData[0].data
+		updated.Data = "Add returns a plus b"
+		updated.Filename = "add.go"
+
+		id, err := vs.UpsertChunk(context.Background(), updated, updated.Hash())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if id != ids[0] {
+			t.Errorf("expected ID %d but got %d", ids[0], id)
+		}
+
+		c, ok, err := vs.GetChunk(context.Background(), keyFor(updated), testModel, 3)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !ok {
+			t.Fatal("missing chunk")
+		}
+		if c.Data.Data != updated.String() {
+			t.Errorf("expected %q but got %q", updated.String(), c.Data.Data)
+		}
+		if c.Filename != "add.go" {
+			t.Errorf("expected add.go but got %q", c.Filename)
+		}
+		if c.Hash != updated.Hash() {
+			t.Errorf("expected hash %q but got %q", updated.Hash(), c.Hash)
+		}
+		// The embedding is from the previous version of the chunk
+		if c.EmbeddingHash != testData[0].data.Hash() {
+			t.Errorf("expected embedding hash %q but got %q", testData[0].data.Hash(), c.EmbeddingHash)
+		}
+	})
+
+	t.Run("MissingModel", func(t *testing.T) {
+		_, _, err := vs.Search(context.Background(), Query{Model: "other", Embedding: []float32{1, 0, 0}, Limit: 10})
+		if err == nil {
+			t.Error("expected error for model without embeddings")
+		}
+	})
+}
+
+func TestMemoryDelete(t *testing.T) {
+	vs := NewMemory()
+	ids := storeTestData(t, vs)
+
+	if err := vs.Delete(context.Background(), ids[0], ids[1]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	chunks, err := vs.List(context.Background(), "example.com/math")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(chunks) != 0 {
+		t.Errorf("expected no chunks but got %d", len(chunks))
+	}
+
+	_, ok, err := vs.GetChunk(context.Background(), keyFor(testData[0].data), testModel, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Error("expected deleted chunk to be missing")
+	}
+
+	symbols := searchSymbols(t, vs, Query{Model: testModel, Embedding: []float32{1, 0, 0}, Limit: 10})
+	if !slices.Equal(symbols, []string{"sum", "Reader"}) {
+		t.Errorf("expected [sum Reader] but got %v", symbols)
+	}
+
+	// Deleted keys are inserted with a new ID
+	id, err := vs.UpsertChunk(context.Background(), testData[0].data, testData[0].data.Hash())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id == ids[0] {
+		t.Errorf("expected new ID but got %d", id)
+	}
+}
+
+func TestMemorySearch(t *testing.T) {
+	vs := NewMemory()
+	storeTestData(t, vs)
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected []string
+	}{
+		{
+			"OrderedBySimilarity",
+			Query{Model: testModel, Embedding: []float32{1, 0, 0}, Limit: 10},
+			[]string{"Add", "sum", "Sub", "Reader"},
+		},
+		{
+			"Limit",
+			Query{Model: testModel, Embedding: []float32{1, 0, 0}, Limit: 2},
+			[]string{"Add", "sum"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			symbols := searchSymbols(t, vs, tt.query)
+			if !slices.Equal(symbols, tt.expected) {
+				t.Errorf("expected %v but got %v", tt.expected, symbols)
+			}
+		})
+	}
+}
+
+func TestMemoryDimensions(t *testing.T) {
+	vs := NewMemory()
+	ids := storeTestData(t, vs)
+
+	c, ok, err := vs.GetChunk(context.Background(), keyFor(testData[1].data), testModel, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok {
+		t.Fatal("missing chunk")
+	}
+	if c.EmbeddingHash != "" {
+		t.Errorf("expected no embedding for dimension 2 but got hash %q", c.EmbeddingHash)
+	}
+
+	err = vs.UpsertEmbedding(context.Background(), ids[1], testModel, "hash", []float32{0, 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	symbols := searchSymbols(t, vs, Query{Model: testModel, Embedding: []float32{0, 1}, Limit: 10})
+	if !slices.Equal(symbols, []string{"Sub"}) {
+		t.Errorf("expected only Sub for dimension 2 but got %v", symbols)
+	}
+
+	embeddings, err := vs.GetEmbeddings(context.Background(), testModel, 3, []Key{keyFor(testData[1].data)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(embeddings[keyFor(testData[1].data)], testData[1].embedding) {
+		t.Errorf("expected embedding %v but got %v", testData[1].embedding, embeddings[keyFor(testData[1].data)])
+	}
+}
+
+func TestMemoryKeywordSearch(t *testing.T) {
+	vs := NewMemory()
+	storeTestData(t, vs)
+
+	dataIter, getErr, err := vs.KeywordSearch(context.Background(), "reader bytes", 10, godocrag.Filter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var symbols []string
+	for d := range dataIter {
+		if d.Score <= 0 || d.Score >= 1 {
+			t.Errorf("expected score between 0 and 1 but got %f", d.Score)
+		}
+		symbols = append(symbols, d.Symbol)
+	}
+	if err := getErr(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(symbols, []string{"Reader"}) {
+		t.Errorf("expected [Reader] but got %v", symbols)
+	}
+}
+
+func TestMemorySearchFilter(t *testing.T) {
+	vs := NewMemory()
+	storeTestData(t, vs)
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected []string
+	}{
+		{
+			"PackagePrefix",
+			Query{Model: testModel, Embedding: []float32{1, 0, 0}, Limit: 10, Filter: godocrag.Filter{PackagePrefix: "example.com/math"}},
+			[]string{"Add", "sum", "Sub"},
+		},
+		{
+			"Types",
+			Query{Model: testModel, Embedding: []float32{1, 0, 0}, Limit: 10, Filter: godocrag.Filter{Types: []string{"struct"}}},
+			[]string{"Reader"},
+		},
+		{
+			"ExportedOnly",
+			Query{Model: testModel, Embedding: []float32{1, 0, 0}, Limit: 10, Filter: godocrag.Filter{ExportedOnly: true}},
+			[]string{"Add", "Sub", "Reader"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			symbols := searchSymbols(t, vs, tt.query)
+			if !slices.Equal(symbols, tt.expected) {
+				t.Errorf("expected %v but got %v", tt.expected, symbols)
+			}
+		})
+	}
+}
+
+func TestMemorySearchScore(t *testing.T) {
+	vs := NewMemory()
+	storeTestData(t, vs)
+
+	dataIter, _, err := vs.Search(context.Background(), Query{Model: testModel, Embedding: []float32{0, 1, 0}, Limit: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for d := range dataIter {
+		if d.Symbol != "Sub" || d.Score < 0.599 || d.Score > 0.601 {
+			t.Errorf("expected Sub with score 0.6 but got %s with %f", d.Symbol, d.Score)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrSchemaOutdated is returned when the database schema is older than the latest migration
+var ErrSchemaOutdated = errors.New("database schema is outdated")
+
+// Migrator is implemented by stores with a schema that is upgraded in place with versioned migrations
+type Migrator interface {
+	// Migrate applies all migrations that have not been applied yet and returns them
+	Migrate(ctx context.Context) ([]Migration, error)
+	// CheckSchema returns ErrSchemaOutdated if there are migrations that have not been applied
+	CheckSchema(ctx context.Context) error
+}
+
+// Migration is a versioned change to the database schema. Migrations are applied in order of
+// their version and each one is only applied once
+type Migration struct {
+	Version     int
+	Description string
+
+	up func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migrationLockID is used with pg_advisory_xact_lock so only one process applies migrations at a time
+const migrationLockID = 7_132_240_517
+
+// migrations are applied in order. Migrations only use statements like IF NOT EXISTS so databases
+// created by the init.sql from earlier versions can also be upgraded. Never change a migration
+// that has been released; add a new one instead
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create comment_data and embeddings tables",
+		up: execMigration(
+			`CREATE EXTENSION IF NOT EXISTS vector`,
+			`CREATE TABLE IF NOT EXISTS comment_data (
+				id       SERIAL PRIMARY KEY,
+				data     TEXT,
+				package  TEXT,
+				filename TEXT,
+				symbol   TEXT,
+				type     TEXT,
+				UNIQUE(package, filename, symbol, type)
+			)`,
+			`CREATE TABLE IF NOT EXISTS embeddings (
+				id INTEGER PRIMARY KEY REFERENCES comment_data(id),
+				embedding vector(768)
+			)`,
+		),
+	},
+	{
+		Version:     2,
+		Description: "add signature, line, and undocumented columns",
+		up: execMigration(
+			`ALTER TABLE comment_data ADD COLUMN IF NOT EXISTS signature TEXT`,
+			`ALTER TABLE comment_data ADD COLUMN IF NOT EXISTS line INTEGER`,
+			`ALTER TABLE comment_data ADD COLUMN IF NOT EXISTS undocumented BOOLEAN DEFAULT FALSE`,
+		),
+	},
+	{
+		Version:     3,
+		Description: "add content hash and embedding model columns",
+		up: execMigration(
+			`ALTER TABLE comment_data ADD COLUMN IF NOT EXISTS content_hash TEXT`,
+			`ALTER TABLE embeddings ADD COLUMN IF NOT EXISTS model TEXT`,
+		),
+	},
+	{
+		Version:     4,
+		Description: "make symbols unique within a package",
+		up: execMigration(
+			// Keep the newest row for symbols that were stored for multiple files
+			`DELETE FROM embeddings WHERE id IN (
+				SELECT id FROM comment_data c WHERE EXISTS (
+					SELECT 1 FROM comment_data n
+					WHERE n.package = c.package AND n.symbol = c.symbol AND n.type = c.type AND n.id > c.id
+				)
+			)`,
+			`DELETE FROM comment_data c WHERE EXISTS (
+				SELECT 1 FROM comment_data n
+				WHERE n.package = c.package AND n.symbol = c.symbol AND n.type = c.type AND n.id > c.id
+			)`,
+			`ALTER TABLE comment_data DROP CONSTRAINT IF EXISTS comment_data_package_filename_symbol_type_key`,
+			`ALTER TABLE comment_data DROP CONSTRAINT IF EXISTS comment_data_package_symbol_type_key`,
+			`ALTER TABLE comment_data ADD CONSTRAINT comment_data_package_symbol_type_key UNIQUE(package, symbol, type)`,
+		),
+	},
+	{
+		Version:     5,
+		Description: "store embeddings in a table for each model and dimension",
+		up:          migrateModelTables,
+	},
+	{
+		Version:     6,
+		Description: "add full-text search over symbols, packages, and doc text",
+		up: execMigration(
+			`ALTER TABLE comment_data ADD COLUMN IF NOT EXISTS search_vector tsvector`,
+			// Existing rows are approximated here and updated with split identifiers when they are
+			// embedded again
+			`UPDATE comment_data SET search_vector =
+				setweight(to_tsvector('simple', regexp_replace(COALESCE(symbol, ''), '[^[:alnum:]_]+', ' ', 'g')), 'A') ||
+				setweight(to_tsvector('simple', regexp_replace(COALESCE(package, ''), '[^[:alnum:]_]+', ' ', 'g')), 'B') ||
+				setweight(to_tsvector('simple', regexp_replace(COALESCE(data, ''), '[^[:alnum:]_]+', ' ', 'g')), 'C')
+			WHERE search_vector IS NULL`,
+			`CREATE INDEX IF NOT EXISTS comment_data_search_idx ON comment_data USING GIN (search_vector)`,
+		),
+	},
+	{
+		Version:     7,
+		Description: "add module and exported columns for filtering searches",
+		up: execMigration(
+			`ALTER TABLE comment_data ADD COLUMN IF NOT EXISTS module TEXT`,
+			`ALTER TABLE comment_data ADD COLUMN IF NOT EXISTS module_version TEXT`,
+			`ALTER TABLE comment_data ADD COLUMN IF NOT EXISTS exported BOOLEAN`,
+			// Modules are set when chunks are embedded again. Symbols are exported if every part
+			// of the name is exported, which matches Data.Exported for everything but examples
+			`UPDATE comment_data SET exported = type = 'package' OR
+				regexp_replace(symbol, '^\*', '') !~ '(^|\.)\*?[^[:upper:]]'
+			WHERE exported IS NULL`,
+			`CREATE INDEX IF NOT EXISTS comment_data_package_idx ON comment_data (package text_pattern_ops)`,
+		),
+	},
+}
+
+// execMigration creates a migration that executes each statement
+func execMigration(statements ...string) func(context.Context, *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		for _, stmt := range statements {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// migrateModelTables moves embeddings from the single embeddings table to a table for each model.
+// Embeddings without a model can't be moved, so they are regenerated the next time chunks are embedded
+func migrateModelTables(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS embedding_models (
+		model      TEXT NOT NULL,
+		dimension  INTEGER NOT NULL,
+		table_name TEXT UNIQUE NOT NULL,
+		PRIMARY KEY (model, dimension)
+	)`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT DISTINCT model FROM embeddings WHERE model IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	var models []string
+	for rows.Next() {
+		var model string
+		if err := rows.Scan(&model); err != nil {
+			rows.Close()
+			return err
+		}
+		models = append(models, model)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// The embeddings table always used vector(768)
+	const dimension = 768
+	for _, model := range models {
+		table := modelTableName(model, dimension)
+		if _, err := tx.ExecContext(ctx, createModelTableQuery(table, dimension)); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (id, content_hash, embedding)
+			SELECT e.id, c.content_hash, e.embedding
+			FROM embeddings e
+			JOIN comment_data c ON c.id = e.id
+			WHERE e.model = $1
+			ON CONFLICT (id) DO NOTHING`, table), model)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO embedding_models (model, dimension, table_name) VALUES ($1, $2, $3)
+			ON CONFLICT (model, dimension) DO NOTHING`,
+			model, dimension, table,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DROP TABLE embeddings`)
+	return err
+}
+
+// Migrate applies all migrations that have not been applied yet. All migrations are applied in a
+// single transaction, so the schema is not changed if any of them fail
+func (p Postgres) Migrate(ctx context.Context) ([]Migration, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return nil, fmt.Errorf("failed to lock schema migrations: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version     INTEGER PRIMARY KEY,
+		description TEXT,
+		applied_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	version, err := schemaVersion(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range migrations {
+		if m.Version <= version {
+			continue
+		}
+
+		if err := m.up(ctx, tx); err != nil {
+			return nil, fmt.Errorf("failed to apply migration %d (%s): %w", m.Version, m.Description, err)
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version, description) VALUES ($1, $2)`,
+			m.Version, m.Description,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+		}
+
+		applied = append(applied, m)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit migrations: %w", err)
+	}
+
+	return applied, nil
+}
+
+// CheckSchema returns ErrSchemaOutdated if the database has not been migrated to the latest version
+func (p Postgres) CheckSchema(ctx context.Context) error {
+	var exists bool
+	err := p.db.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check schema version: %w", err)
+	}
+
+	version := 0
+	if exists {
+		version, err = schemaVersion(ctx, p.db)
+		if err != nil {
+			return err
+		}
+	}
+
+	latest := migrations[len(migrations)-1].Version
+	if version < latest {
+		return fmt.Errorf("%w: version %d is behind latest version %d", ErrSchemaOutdated, version, latest)
+	}
+
+	return nil
+}
+
+// schemaVersion returns the version of the latest migration that was applied
+func schemaVersion(ctx context.Context, q interface {
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}) (int, error) {
+	var version int
+	err := q.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return version, nil
+}
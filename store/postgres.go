@@ -0,0 +1,476 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"iter"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	godocrag "godoc-rag"
+
+	"github.com/lib/pq"
+)
+
+// Postgres implements VectorStore using PostgreSQL with the pgvector extension. Embeddings for
+// each model are stored in a separate table that is created when the model is first used, so the
+// vector column can have the model's dimension. The rest of the schema is created by Migrate
+type Postgres struct {
+	db     *sql.DB
+	tables *modelTables
+}
+
+var (
+	_ VectorStore = Postgres{}
+	_ Migrator    = Postgres{}
+)
+
+// modelTables caches the embedding table for each model and dimension
+type modelTables struct {
+	mu     sync.Mutex
+	tables map[modelTableKey]modelTable
+}
+
+type modelTableKey struct {
+	model     string
+	dimension int
+}
+
+type modelTable struct {
+	name      string
+	dimension int
+}
+
+func NewPostgres(db *sql.DB) Postgres {
+	return Postgres{db, &modelTables{tables: map[modelTableKey]modelTable{}}}
+}
+
+func (p Postgres) UpsertChunk(ctx context.Context, data godocrag.Data, hash string) (int, error) {
+	symbolWords, packageWords, dataWords := searchVectorText(data)
+
+	var id int
+	err := p.db.QueryRowContext(ctx,
+		`INSERT INTO comment_data (data, package, filename, symbol, type, signature, line, undocumented, content_hash,
+				module, module_version, exported, search_vector)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12,
+				setweight(to_tsvector('simple', $13), 'A') ||
+				setweight(to_tsvector('simple', $14), 'B') ||
+				setweight(to_tsvector('simple', $15), 'C'))
+			ON CONFLICT (package, symbol, type)
+			DO UPDATE SET
+				data = EXCLUDED.data,
+				filename = EXCLUDED.filename,
+				signature = EXCLUDED.signature,
+				line = EXCLUDED.line,
+				undocumented = EXCLUDED.undocumented,
+				content_hash = EXCLUDED.content_hash,
+				module = EXCLUDED.module,
+				module_version = EXCLUDED.module_version,
+				exported = EXCLUDED.exported,
+				search_vector = EXCLUDED.search_vector
+			RETURNING id`,
+		data.String(), data.Package, data.Filename, data.Symbol, data.Type, data.Signature, data.Line, data.Undocumented, hash,
+		data.Module, data.ModuleVersion, data.Exported(),
+		symbolWords, packageWords, dataWords,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert chunk: %v", err)
+	}
+
+	return id, nil
+}
+
+func (p Postgres) UpsertEmbedding(ctx context.Context, id int, model, hash string, embedding []float32) error {
+	table, err := p.createModelTable(ctx, model, len(embedding))
+	if err != nil {
+		return err
+	}
+
+	_, err = p.db.ExecContext(ctx,
+		fmt.Sprintf(`INSERT INTO %s (id, content_hash, embedding)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET
+			content_hash = EXCLUDED.content_hash,
+			embedding = EXCLUDED.embedding`, table.name),
+		id, hash, vectorLiteral(embedding),
+	)
+	return err
+}
+
+func (p Postgres) GetChunk(ctx context.Context, key Key, model string, dimension int) (Chunk, bool, error) {
+	table, ok, err := p.getModelTable(ctx, model, dimension)
+	if err != nil {
+		return Chunk{}, false, err
+	}
+
+	// Use an empty result for the embedding if the model and dimension do not have a table yet
+	embeddings := `(SELECT NULL::INTEGER AS id, NULL::TEXT AS content_hash)`
+	if ok {
+		embeddings = table.name
+	}
+
+	var c Chunk
+	var hash, embeddingHash sql.NullString
+	err = p.db.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT c.id, c.data, c.package, c.filename, c.symbol, c.type, c.content_hash, e.content_hash
+			FROM comment_data c
+			LEFT JOIN %s e ON c.id = e.id
+			WHERE c.package = $1 AND c.symbol = $2 AND c.type = $3`, embeddings),
+		key.Package, key.Symbol, key.Type,
+	).Scan(&c.ID, &c.Data.Data, &c.Package, &c.Filename, &c.Symbol, &c.Type, &hash, &embeddingHash)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return Chunk{}, false, nil
+	case err != nil:
+		return Chunk{}, false, fmt.Errorf("failed to get stored chunk: %w", err)
+	}
+
+	c.Hash = hash.String
+	c.EmbeddingHash = embeddingHash.String
+	return c, true, nil
+}
+
+func (p Postgres) GetEmbeddings(ctx context.Context, model string, dimension int, keys []Key) (map[Key][]float32, error) {
+	result := map[Key][]float32{}
+
+	table, ok, err := p.getModelTable(ctx, model, dimension)
+	if err != nil || !ok || len(keys) == 0 {
+		return result, err
+	}
+
+	packages := make([]string, len(keys))
+	symbols := make([]string, len(keys))
+	types := make([]string, len(keys))
+	for i, key := range keys {
+		packages[i], symbols[i], types[i] = key.Package, key.Symbol, key.Type
+	}
+
+	rows, err := p.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT c.package, c.symbol, c.type, e.embedding::text
+		FROM unnest($1::text[], $2::text[], $3::text[]) AS k(package, symbol, type)
+		JOIN comment_data c ON c.package = k.package AND c.symbol = k.symbol AND c.type = k.type
+		JOIN %s e ON c.id = e.id
+	`, table.name), pq.Array(packages), pq.Array(symbols), pq.Array(types))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key Key
+		var vector string
+		if err := rows.Scan(&key.Package, &key.Symbol, &key.Type, &vector); err != nil {
+			return nil, err
+		}
+
+		result[key], err = parseVector(vector)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, rows.Err()
+}
+
+func (p Postgres) List(ctx context.Context, pkg string) ([]Chunk, error) {
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT id, filename, symbol, type FROM comment_data WHERE package = $1 ORDER BY id`,
+		pkg,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stored chunks for %s: %w", pkg, err)
+	}
+	defer rows.Close()
+
+	var result []Chunk
+	for rows.Next() {
+		c := Chunk{Data: godocrag.Data{Package: pkg}}
+		if err := rows.Scan(&c.ID, &c.Filename, &c.Symbol, &c.Type); err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+
+	return result, rows.Err()
+}
+
+// Delete removes the chunks. Embeddings are deleted by the ON DELETE CASCADE on each model's table
+func (p Postgres) Delete(ctx context.Context, ids ...int) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM comment_data WHERE id = $1`, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (p Postgres) Search(ctx context.Context, q Query) (iter.Seq[godocrag.Data], func() error, error) {
+	table, ok, err := p.getModelTable(ctx, q.Model, len(q.Embedding))
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("no embeddings stored for model %q with dimension %d", q.Model, len(q.Embedding))
+	}
+
+	// Use a transaction so the search parameters are only set for this query
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := setSearchParams(ctx, tx, q.SearchParams); err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	args := []any{vectorLiteral(q.Embedding), q.Limit}
+	where, args := filterClause(q.Filter, args)
+
+	// Query database for similar chunks using cosine similarity. Approximate indexes apply the
+	// filter after finding the nearest embeddings, so filtered searches can return fewer results
+	// unless ef_search or probes is increased
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %s, 1 - (e.embedding <=> $1) AS score
+		FROM comment_data c
+		JOIN %s e ON c.id = e.id
+		WHERE %s
+		ORDER BY e.embedding <=> $1 LIMIT $2
+	`, dataColumns, table.name, where), args...)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, fmt.Errorf("failed to query similar chunks: %v", err)
+	}
+
+	var errorResult error
+	return func(yield func(godocrag.Data) bool) {
+			defer tx.Rollback()
+			defer rows.Close()
+
+			for rows.Next() {
+				d, err := scanData(rows)
+				if err != nil {
+					errorResult = err
+					return
+				}
+
+				if !yield(d) {
+					return
+				}
+			}
+
+			if err := rows.Err(); err != nil {
+				errorResult = err
+				return
+			}
+		}, func() error {
+			return errorResult
+		}, nil
+}
+
+// dataColumns are the columns read by scanData, which must be followed by the score
+const dataColumns = `c.data, c.package, c.filename, c.symbol, c.type, COALESCE(c.signature, ''),
+	COALESCE(c.line, 0), COALESCE(c.undocumented, FALSE), COALESCE(c.module, ''), COALESCE(c.module_version, '')`
+
+func scanData(rows *sql.Rows) (godocrag.Data, error) {
+	var d godocrag.Data
+	err := rows.Scan(
+		&d.Data, &d.Package, &d.Filename, &d.Symbol, &d.Type, &d.Signature, &d.Line, &d.Undocumented,
+		&d.Module, &d.ModuleVersion, &d.Score,
+	)
+	return d, err
+}
+
+// filterClause returns a condition for the filter using placeholders after the existing args,
+// and the args with the filter's values added
+func filterClause(filter godocrag.Filter, args []any) (string, []any) {
+	conditions := []string{"TRUE"}
+	add := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if prefix := strings.TrimSuffix(filter.PackagePrefix, "/"); prefix != "" {
+		add("(c.package = $%[1]d OR starts_with(c.package, $%[1]d || '/'))", prefix)
+	}
+	if len(filter.Types) > 0 {
+		add("c.type = ANY($%d)", pq.Array(filter.Types))
+	}
+	if filter.Module != "" {
+		add("c.module = $%d", filter.Module)
+	}
+	if filter.ModuleVersion != "" {
+		add("c.module_version = $%d", filter.ModuleVersion)
+	}
+	if filter.ExportedOnly {
+		conditions = append(conditions, "c.exported")
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// setSearchParams sets the search parameters for the rest of the transaction
+func setSearchParams(ctx context.Context, tx *sql.Tx, params SearchParams) error {
+	var settings []string
+	if params.EfSearch > 0 {
+		settings = append(settings, fmt.Sprintf("SET LOCAL hnsw.ef_search = %d", params.EfSearch))
+	}
+	if params.Probes > 0 {
+		settings = append(settings, fmt.Sprintf("SET LOCAL ivfflat.probes = %d", params.Probes))
+	}
+	if params.Exact {
+		settings = append(settings, "SET LOCAL enable_indexscan = off")
+	}
+
+	for _, setting := range settings {
+		if _, err := tx.ExecContext(ctx, setting); err != nil {
+			return fmt.Errorf("failed to set search parameters: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (p Postgres) Close() error {
+	return p.db.Close()
+}
+
+// getModelTable returns the embedding table for a model and dimension and false if it does not
+// exist yet
+func (p Postgres) getModelTable(ctx context.Context, model string, dimension int) (modelTable, bool, error) {
+	p.tables.mu.Lock()
+	defer p.tables.mu.Unlock()
+
+	return p.getModelTableLocked(ctx, model, dimension)
+}
+
+func (p Postgres) getModelTableLocked(ctx context.Context, model string, dimension int) (modelTable, bool, error) {
+	key := modelTableKey{model, dimension}
+	if table, ok := p.tables.tables[key]; ok {
+		return table, true, nil
+	}
+
+	table := modelTable{dimension: dimension}
+	err := p.db.QueryRowContext(ctx,
+		`SELECT table_name FROM embedding_models WHERE model = $1 AND dimension = $2`,
+		model, dimension,
+	).Scan(&table.name)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return modelTable{}, false, nil
+	case err != nil:
+		return modelTable{}, false, fmt.Errorf("failed to get embedding table for model %q with dimension %d: %w", model, dimension, err)
+	}
+
+	p.tables.tables[key] = table
+	return table, true, nil
+}
+
+// createModelTable returns the embedding table for a model and dimension, creating it if it does
+// not exist
+func (p Postgres) createModelTable(ctx context.Context, model string, dimension int) (modelTable, error) {
+	p.tables.mu.Lock()
+	defer p.tables.mu.Unlock()
+
+	table, ok, err := p.getModelTableLocked(ctx, model, dimension)
+	if err != nil {
+		return modelTable{}, err
+	}
+	if ok {
+		return table, nil
+	}
+
+	table = modelTable{name: modelTableName(model, dimension), dimension: dimension}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return modelTable{}, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, createModelTableQuery(table.name, dimension))
+	if err != nil {
+		return modelTable{}, fmt.Errorf("failed to create embedding table for model %q: %w", model, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO embedding_models (model, dimension, table_name) VALUES ($1, $2, $3)
+		ON CONFLICT (model, dimension) DO NOTHING`,
+		model, dimension, table.name,
+	)
+	if err != nil {
+		return modelTable{}, fmt.Errorf("failed to register embedding model %q: %w", model, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return modelTable{}, err
+	}
+
+	p.tables.tables[modelTableKey{model, dimension}] = table
+	return table, nil
+}
+
+// createModelTableQuery returns the query to create an embedding table for a model
+func createModelTableQuery(table string, dimension int) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER PRIMARY KEY REFERENCES comment_data(id) ON DELETE CASCADE,
+		content_hash TEXT,
+		embedding vector(%d)
+	)`, table, dimension)
+}
+
+var invalidTableChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// modelTableName creates a valid table name for the model. A hash is included so different model
+// names do not create the same table name after removing invalid characters
+func modelTableName(model string, dimension int) string {
+	name := invalidTableChars.ReplaceAllString(strings.ToLower(model), "_")
+	name = strings.Trim(name, "_")
+	if len(name) > 32 {
+		name = name[:32]
+	}
+
+	sum := sha256.Sum256([]byte(model))
+	return fmt.Sprintf("embeddings_%s_%d_%s", name, dimension, hex.EncodeToString(sum[:4]))
+}
+
+// parseVector parses the text representation of a pgvector vector, like [1,2,3]
+func parseVector(s string) ([]float32, error) {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	if s == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(s, ",")
+	vector := make([]float32, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(part, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vector: %w", err)
+		}
+		vector[i] = float32(v)
+	}
+
+	return vector, nil
+}
+
+// vectorLiteral converts a vector to a Postgres array literal
+func vectorLiteral(vector []float32) string {
+	strVals := make([]string, len(vector))
+	for i, v := range vector {
+		strVals[i] = fmt.Sprintf("%f", v)
+	}
+	return fmt.Sprintf("[%s]", strings.Join(strVals, ","))
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"iter"
+	"strings"
+
+	godocrag "godoc-rag"
+)
+
+// Key identifies a stored chunk. The parser sends one Data for each Key since declarations are
+// unique within a package, and package docs and the const groups for each enum type are combined
+// across files. A symbol that moves to a different file keeps the same Key
+type Key struct {
+	Package string
+	Symbol  string
+	Type    string
+}
+
+// Chunk is Data that is stored in a VectorStore
+type Chunk struct {
+	godocrag.Data
+
+	ID int
+	// Hash is the content hash of the stored Data
+	Hash string
+	// EmbeddingHash is the content hash of the Data when the embedding for the requested model was
+	// generated, or empty if there is no embedding for the model. It is used to detect changes
+	EmbeddingHash string
+}
+
+// Query is a similarity search for the stored embeddings nearest to an embedding
+type Query struct {
+	// Model is the embedding model used to generate the embedding. Only embeddings from the same
+	// model with the same dimension as the Embedding are searched
+	Model     string
+	Embedding []float32
+	Limit     int
+	Filter    godocrag.Filter
+
+	SearchParams
+}
+
+// SearchParams tune approximate nearest neighbor indexes for a single query. Zero values use the
+// store's defaults. Stores that always use exact search ignore them
+type SearchParams struct {
+	// EfSearch is the size of the candidate list for HNSW indexes. Higher values improve recall
+	// but are slower
+	EfSearch int
+	// Probes is the number of lists searched in IVFFlat indexes. Higher values improve recall but
+	// are slower
+	Probes int
+	// Exact disables approximate indexes so the results are exact
+	Exact bool
+}
+
+// VectorStore stores parsed Data along with its embeddings and searches them by similarity.
+// Embeddings are stored separately for each model and dimension so multiple models, or one model
+// with multiple dimensions, can be used with the same chunks
+type VectorStore interface {
+	// UpsertChunk creates or updates the chunk with the same Key as the Data and returns its ID
+	UpsertChunk(ctx context.Context, data godocrag.Data, hash string) (int, error)
+	// UpsertEmbedding creates or updates the model's embedding for a chunk. The hash is the
+	// content hash of the chunk that was embedded
+	UpsertEmbedding(ctx context.Context, id int, model, hash string, embedding []float32) error
+	// GetChunk returns the stored chunk with the Key, including the EmbeddingHash for the model
+	// and dimension. It returns false if the chunk does not exist
+	GetChunk(ctx context.Context, key Key, model string, dimension int) (Chunk, bool, error)
+	// GetEmbeddings returns the model's embeddings with the dimension for the chunks with the
+	// keys. Chunks that do not have an embedding for the model and dimension are not included
+	GetEmbeddings(ctx context.Context, model string, dimension int, keys []Key) (map[Key][]float32, error)
+	// List returns the stored chunks in a package
+	List(ctx context.Context, pkg string) ([]Chunk, error)
+	// Delete removes chunks and their embeddings
+	Delete(ctx context.Context, ids ...int) error
+	// Search returns the chunks with embeddings from the query's model that are nearest to the
+	// query embedding. The Score of each result is its cosine similarity to the query embedding.
+	// Errors that occur while iterating are returned by the function
+	Search(ctx context.Context, q Query) (iter.Seq[godocrag.Data], func() error, error)
+	// KeywordSearch returns the chunks matching the filter that best match the words in the text,
+	// ranking matches in the symbol higher than the package and doc text. The Score of each result
+	// is from 0 to 1. Errors that occur while iterating are returned by the function
+	KeywordSearch(ctx context.Context, text string, limit int, filter godocrag.Filter) (iter.Seq[godocrag.Data], func() error, error)
+	// Close releases resources used by the store
+	Close() error
+}
+
+// Open creates a VectorStore from a connection string. Paths like file:///path/to/index.db use a
+// local File store, memory:// uses a Memory store, and anything else is used as a Postgres
+// connection string
+func Open(connStr string) (VectorStore, error) {
+	if path, ok := strings.CutPrefix(connStr, "file://"); ok {
+		f, err := OpenFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	if connStr == "memory://" {
+		return NewMemory(), nil
+	}
+
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect: %w", err)
+	}
+	return NewPostgres(db), nil
+}
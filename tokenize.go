@@ -0,0 +1,54 @@
+package godocrag
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenize splits text into lowercase words. Identifiers like UpdateEmail and update_email are
+// also split into their parts so they match natural language queries
+func Tokenize(text string) []string {
+	var result []string
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	for _, field := range fields {
+		parts := splitIdentifier(field)
+		if len(parts) > 1 {
+			result = append(result, strings.ToLower(field))
+		}
+		for _, part := range parts {
+			result = append(result, strings.ToLower(part))
+		}
+	}
+	return result
+}
+
+// splitIdentifier splits camel case and snake case identifiers into words
+func splitIdentifier(s string) []string {
+	var result []string
+	var current []rune
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case r == '_':
+			if len(current) > 0 {
+				result = append(result, string(current))
+			}
+			current = nil
+			continue
+		case i > 0 && unicode.IsUpper(r) && len(current) > 0:
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				result = append(result, string(current))
+				current = nil
+			}
+		}
+		current = append(current, r)
+	}
+	if len(current) > 0 {
+		result = append(result, string(current))
+	}
+	return result
+}